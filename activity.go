@@ -0,0 +1,42 @@
+package microbatcher
+
+import "time"
+
+// activityChanged schedules a report of the queue becoming active or
+// inactive, once the minimum interval since the previous report has
+// passed. A change that is reversed before it is reported is not reported.
+// The mutex must be held.
+func (b *Batcher[A, B]) activityChanged() {
+	if b.onActivity == nil {
+		return
+	}
+
+	if (len(b.jobs) > 0) == b.reportedActive {
+		b.activityTimer.Stop()
+		return
+	}
+
+	b.activityTimer.Reset(max(0, b.activityInterval-time.Since(b.lastReport)))
+}
+
+// reportActivity calls the activity callback if the queue has become active
+// or inactive since the previous report.
+func (b *Batcher[A, B]) reportActivity() {
+	b.reportMu.Lock()
+	defer b.reportMu.Unlock()
+
+	b.lock()
+
+	active := len(b.jobs) > 0
+	if active == b.reportedActive {
+		b.unlock()
+		return
+	}
+
+	b.reportedActive = active
+	b.lastReport = time.Now()
+
+	b.unlock()
+
+	b.onActivity(active)
+}
@@ -0,0 +1,98 @@
+package microbatcher
+
+import (
+	"testing"
+	"time"
+)
+
+// expectActivity fails the test unless the next reported activity is the
+// expected state.
+func expectActivity(t *testing.T, reports <-chan bool, expected bool) {
+	t.Helper()
+
+	select {
+	case active := <-reports:
+		if active != expected {
+			t.Errorf("activity reported as %t, expected %t", active, expected)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("activity was not reported as %t", expected)
+	}
+}
+
+func TestBatcherActivityCallback(t *testing.T) {
+	reports := make(chan bool, 10)
+	callback := func(active bool) {
+		reports <- active
+	}
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithActivityCallback[string, string](callback, 0))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	addJobs(t, b, "foobar")
+	expectActivity(t, reports, true)
+
+	// Adding to a queue that is already active is not reported.
+	addJobs(t, b, "baz")
+
+	b.Flush()
+	expectActivity(t, reports, false)
+
+	if len(reports) != 0 {
+		t.Error("activity was reported without a change")
+	}
+}
+
+func TestBatcherActivityCallbackDebounces(t *testing.T) {
+	reports := make(chan bool, 10)
+	callback := func(active bool) {
+		reports <- active
+	}
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithActivityCallback[string, string](callback, 50*time.Millisecond))
+
+	go b.Start()
+
+	addJobs(t, b, "foobar")
+	expectActivity(t, reports, true)
+
+	// Rapid changes within the interval collapse into the final state.
+	b.Flush()
+	addJobs(t, b, "foobar")
+	b.Flush()
+
+	expectActivity(t, reports, false)
+
+	b.Shutdown()
+
+	if len(reports) != 0 {
+		t.Error("rapid changes were not debounced")
+	}
+}
+
+func TestBatcherActivityCallbackReportsShutdown(t *testing.T) {
+	reports := make(chan bool, 10)
+	callback := func(active bool) {
+		reports <- active
+	}
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithActivityCallback[string, string](callback, FIVE_MINUTES))
+
+	go b.Start()
+
+	addJobs(t, b, "foobar")
+	expectActivity(t, reports, true)
+
+	b.Shutdown()
+
+	select {
+	case active := <-reports:
+		if active {
+			t.Error("queue was reported active on shutdown")
+		}
+	default:
+		t.Error("queue becoming inactive was not reported before shutdown returned")
+	}
+}
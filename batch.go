@@ -1,169 +1,1566 @@
 package microbatcher
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
 	"sync"
 	"time"
 )
 
+var (
+	// ErrShuttingDown is returned when a job is submitted to a Batcher
+	// that is in the process of shutting down.
+	ErrShuttingDown = errors.New("failed to add job; batcher is shutting down")
+	// ErrResultInUse is returned by AddJobInto when the supplied JobResult
+	// is nil or still waiting on the result of a previous job.
+	ErrResultInUse = errors.New("failed to add job; job result is still in use")
+	// ErrSealed is returned when a job is submitted to a Batcher that has
+	// been sealed.
+	ErrSealed = errors.New("failed to add job; batcher is sealed")
+	// ErrNotStarted is returned when a job is submitted to, or a flush is
+	// requested from, a Batcher that requires Start to be called first.
+	ErrNotStarted = errors.New("batcher has not been started")
+	// ErrMemoryLimit is returned when a job is submitted to a Batcher
+	// whose queued jobs would exceed its memory limit if it were queued.
+	ErrMemoryLimit = errors.New("failed to add job; batcher memory limit reached")
+	// ErrQueueFull is returned when a job is submitted to a Batcher whose
+	// queue has reached its maximum size.
+	ErrQueueFull = errors.New("failed to add job; batcher queue is full")
+	// ErrBatchSizeMismatch is returned for each job in a batch when the
+	// batch processor returns a different number of results to jobs, or
+	// the batch transform a different number of elements.
+	ErrBatchSizeMismatch = errors.New("batch processor returned an incorrect number of results")
+	// ErrCannotResubmit is returned by Resubmit when the result does not
+	// belong to a job submitted to the Batcher.
+	ErrCannotResubmit = errors.New("failed to resubmit job; result has no job for this batcher")
+	// ErrInvalidWatermark is returned by WaitUntilBelow when the queue
+	// length to wait for is not positive.
+	ErrInvalidWatermark = errors.New("queue watermark must be positive")
+	// ErrResultNotEmitted is returned for each job in a batch that the
+	// streaming processor returned without emitting a result for.
+	ErrResultNotEmitted = errors.New("streaming processor did not emit a result")
+	// ErrQueueTimeout is returned for a job that waited on the queue for
+	// longer than the maximum queue wait without being flushed.
+	ErrQueueTimeout = errors.New("job timed out waiting on the queue")
+	// ErrInvalidConcurrency is returned by SetConcurrency when the number
+	// of workers is not positive.
+	ErrInvalidConcurrency = errors.New("concurrency must be positive")
+	// ErrFatal can be wrapped by the error a processor returns to indicate
+	// that the downstream is permanently unavailable, so that a Batcher
+	// using WithShutdownOnFatal shuts itself down. It is never retried.
+	ErrFatal = errors.New("fatal processor error")
+)
+
+// Ensure the Batcher can be managed as an io.Closer.
+var _ io.Closer = (*Batcher[any, any])(nil)
+
 // Job represents a job to be processed by the Batcher.
 type Job[A any] struct {
 	// Id for the job. This should be unique for each job.
 	Id int
 	// Data required to process the job.
 	Data A
+	// Priority class of the job, used to compose batches when weighted
+	// priority is enabled and ignored otherwise.
+	Priority int
+	// Context the job was submitted with, if any.
+	ctx context.Context
+}
+
+// Context returns the context the job was submitted with by AddJobContext,
+// or the background context if it was submitted without one. The context
+// may already be done by the time the job completes, but its values remain
+// available to hooks such as the result handler.
+func (j Job[A]) Context() context.Context {
+	if j.ctx == nil {
+		return context.Background()
+	}
+
+	return j.ctx
 }
 
+// JobResult is the result of a job added to a Batcher. Its methods are safe
+// to call from multiple goroutines, in any combination, with every caller
+// seeing the same outcome once it is available.
 type JobResult[B any] struct {
 	JobId int
-	data  *B
-	ch    chan B
+	ch    chan outcome[B]
+	// Function that produces the result in place of the channel, used by
+	// results derived from another JobResult.
+	resolve func() (B, error)
+	// The Job the result belongs to, retained for resubmission.
+	job any
+
+	// Mutex guarding the outcome, which is stored as a whole once it has
+	// been received, so that its value, error and duration are always
+	// read together.
+	mu  sync.Mutex
+	out *outcome[B]
+	// Channel closed once the outcome is stored, waking readers that did
+	// not receive it themselves.
+	ready chan struct{}
+	// Mutex held while the outcome is produced by resolve, so that it is
+	// only produced once.
+	resolving sync.Mutex
+}
+
+// Get reads the result of the job from the channel and returns, along with
+// any error returned by the processor. Get can be called any number of
+// times, from any number of goroutines, and always returns the same result.
+func (jr *JobResult[B]) Get() (B, error) {
+	out, _ := jr.wait(context.Background())
+
+	return out.val, out.err
+}
+
+// GetContext reads the result of the job like Get, but returns the
+// context's error if the context is done before the result is available,
+// such as when the caller is serving a request that has been cancelled. The
+// job is still processed, and its result can be read by a later call. A
+// result returned by MapResult waits for its result regardless of the
+// context.
+func (jr *JobResult[B]) GetContext(ctx context.Context) (B, error) {
+	out, err := jr.wait(ctx)
+	if err != nil {
+		var zero B
+		return zero, err
+	}
+
+	return out.val, out.err
+}
+
+// TryGet returns the result of the job like GetResult if it is available,
+// without blocking, and false if the job has not completed yet. A result
+// returned by MapResult is only available once it has been read with Get,
+// GetContext or GetResult.
+func (jr *JobResult[B]) TryGet() (Result[B], bool) {
+	jr.mu.Lock()
+	out, ch, resolve := jr.out, jr.ch, jr.resolve
+	jr.mu.Unlock()
+
+	if out != nil {
+		return jr.result(*out), true
+	}
+
+	if resolve != nil {
+		return Result[B]{JobId: jr.JobId}, false
+	}
+
+	select {
+	case o := <-ch:
+		return jr.result(jr.store(o)), true
+	default:
+		return Result[B]{JobId: jr.JobId}, false
+	}
+}
+
+// GetResult reads the result of the job like Get, returning it along with
+// the wall-clock time spent processing it, so that latency can be
+// attributed to processing rather than waiting on the queue. For a per job
+// processor this is the time taken by the job's own calls, including any
+// retries. For batch processors it is the time taken by the call for the
+// whole batch, shared by every job in it, or until the job's result was
+// emitted by a streaming processor. The duration is zero for jobs that were
+// not processed, such as those served from the result cache, and for
+// results returned by MapResult.
+func (jr *JobResult[B]) GetResult() Result[B] {
+	out, _ := jr.wait(context.Background())
+
+	return jr.result(out)
+}
+
+// result returns the outcome as a Result for the job.
+func (jr *JobResult[B]) result(out outcome[B]) Result[B] {
+	return Result[B]{JobId: jr.JobId, Value: out.val, Err: out.err, Duration: out.duration}
 }
 
-// Get reads the result of the job from the channel and returns.
-func (jr *JobResult[B]) Get() B {
-	if jr.data != nil {
-		return *jr.data
+// wait returns the outcome of the job once it is available, or the
+// context's error if the context is done first. Exactly one reader receives
+// the outcome from the channel and stores it, while any others wait for it
+// to be stored.
+func (jr *JobResult[B]) wait(ctx context.Context) (outcome[B], error) {
+	jr.mu.Lock()
+
+	if jr.out != nil {
+		out := *jr.out
+		jr.mu.Unlock()
+
+		return out, nil
+	}
+
+	if jr.resolve != nil {
+		jr.mu.Unlock()
+		return jr.resolveOutcome(), nil
 	}
 
-	val := <-jr.ch
-	close(jr.ch)
-	jr.data = &val
+	if jr.ready == nil {
+		jr.ready = make(chan struct{})
+	}
+
+	ch, ready := jr.ch, jr.ready
+	jr.mu.Unlock()
+
+	select {
+	case out := <-ch:
+		return jr.store(out), nil
+	case <-ready:
+		jr.mu.Lock()
+		defer jr.mu.Unlock()
+
+		return *jr.out, nil
+	case <-ctx.Done():
+		return outcome[B]{}, ctx.Err()
+	}
+}
+
+// resolveOutcome produces the outcome with resolve, unless another reader
+// already has.
+func (jr *JobResult[B]) resolveOutcome() outcome[B] {
+	jr.resolving.Lock()
+	defer jr.resolving.Unlock()
+
+	jr.mu.Lock()
+	out := jr.out
+	jr.mu.Unlock()
+
+	if out != nil {
+		return *out
+	}
+
+	val, err := jr.resolve()
+
+	return jr.store(outcome[B]{val: val, err: err})
+}
+
+// store records the outcome received from the channel, waking any other
+// readers, and returns it. The first outcome stored is kept.
+func (jr *JobResult[B]) store(out outcome[B]) outcome[B] {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+
+	if jr.out == nil {
+		jr.out = &out
+
+		if jr.ready != nil {
+			close(jr.ready)
+		}
+	}
 
-	return val
+	return *jr.out
+}
+
+// pending reports whether the result is still waiting on the output of a
+// job.
+func (jr *JobResult[B]) pending() bool {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+
+	return jr.ch != nil && jr.out == nil
+}
+
+// reset prepares the result to receive the output of the given job.
+func (jr *JobResult[B]) reset(job any, jobId int, ch chan outcome[B]) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+
+	jr.JobId = jobId
+	jr.job = job
+	jr.ch = ch
+	jr.out = nil
+	jr.ready = nil
+	jr.resolve = nil
+}
+
+// MapResult returns a JobResult that lazily applies f to the output of jr
+// when its Get method is called, without blocking at the mapping site. Any
+// error from jr is returned as is, without f being applied.
+func MapResult[B any, C any](jr *JobResult[B], f func(B) C) *JobResult[C] {
+	return &JobResult[C]{
+		JobId: jr.JobId,
+		resolve: func() (C, error) {
+			val, err := jr.Get()
+			if err != nil {
+				var zero C
+				return zero, err
+			}
+
+			return f(val), nil
+		},
+	}
+}
+
+// Result is the outcome of a job, returned by JobResult.GetResult or sent
+// on the channel a job was added with by AddJobChan.
+type Result[B any] struct {
+	JobId int
+	Value B
+	Err   error
+	// Time spent processing the job, as described by GetResult.
+	Duration time.Duration
+}
+
+// outcome is the result of processing a job, sent to its JobResult.
+type outcome[B any] struct {
+	val      B
+	err      error
+	duration time.Duration
 }
 
 // batchJob is an intermediate structure to hold the original Job and
 // the channel to return a JobResult.
 type batchJob[A any, B any] struct {
 	job   *Job[A]
-	retCh chan B
+	retCh chan outcome[B]
+	// Channel the result is also sent on, if the job was added with one.
+	out chan<- Result[B]
+	// Time the job was added to the queue.
+	enqueued time.Time
+	// Deduplication key of the job, if deduplication is enabled.
+	key any
+	// Result cache key of the job, if result caching is enabled.
+	cacheKey any
+	// Duplicate jobs collapsed into this job, which share its result.
+	merged []*batchJob[A, B]
+	// Error the job was completed with.
+	err error
+	// Whether the job was completed without being processed, by being
+	// abandoned or expiring on the queue, so it is left in the
+	// write-ahead log to be replayed.
+	unprocessed bool
+	// Whether the job is sampled for logging.
+	sampled bool
+	// Time taken to process the job.
+	duration time.Duration
+	// Estimated size in bytes of the job's data, if a memory limit is
+	// configured.
+	size int64
 }
 
 // Batcher represents a unit that receives jobs and processes them in
-// configurable batches.
+// configurable batches. A Batcher must not be copied, and should always be
+// used through the pointer returned by its constructor. Copies are reported
+// by the copylocks check of go vet, as a Batcher holds a sync.Mutex.
 type Batcher[A any, B any] struct {
 	// Function that processes the jobs in the batcher.
-	processor func(A) B
+	processor func(A) (B, error)
+	// Function that processes an entire batch of jobs in one call, used
+	// in place of the processor when set.
+	batchProcessor func(context.Context, []A) ([]B, error)
+	// Function that processes an entire batch of jobs in one call,
+	// emitting each job's result as it becomes available.
+	streamProcessor func(context.Context, []A, func(int, B, error))
 	// Minimum size for a batch of jobs to be processed before timeout.
 	batchSize int
 	// The frequency with which job batches should be processed if
 	// there are inadequate jobs in the queue.
 	frequency time.Duration
+	// Status of Batcher startup.
+	started bool
+	// Whether jobs are rejected until the Batcher has been started.
+	requireStart bool
 	// Status of Batcher shutdown.
 	shuttingDown bool
+	// Whether new jobs are rejected while the queue continues to flush.
+	sealed bool
+	// Order the remaining jobs are drained in on shutdown.
+	shutdownOrder ShutdownOrder
+	// Whether a job failing with ErrFatal shuts down the Batcher, and the
+	// job and error that did so.
+	shutdownOnFatal bool
+	fatalJob        Job[A]
+	fatalErr        error
+	// Errors of the jobs drained on shutdown, returned by Close.
+	shutdownErr error
+	// Ensures the Batcher is only shut down once.
+	shutdownOnce sync.Once
 	// Channel to signal when all remaining jobs are completed.
 	shutdownSignal chan bool
+	// Channel closed to stop the processing loops on shutdown.
+	done chan struct{}
+	// Channel closed when a shutdown drain is cut short, cancelling the
+	// context of batches still being processed.
+	aborted   chan struct{}
+	abortOnce sync.Once
+	// Channel to wake the processing loop when the queue may need to be
+	// flushed.
+	wake chan struct{}
+	// Duration without a new job after which the queue is flushed,
+	// disabled when zero.
+	idleTimeout time.Duration
+	// Timer reset whenever a job is added, signaling the idle channel
+	// when it fires.
+	idleTimer *time.Timer
+	// Channel to notify the processing loop that the queue is idle.
+	idle chan struct{}
+	// Gap without a new job after which the next job is flushed
+	// immediately, disabled when zero.
+	flushFirstAfter time.Duration
+	// Time the most recent job was added.
+	lastAdded time.Time
+	// Whether the first job after an idle gap is waiting to be flushed.
+	flushFirst bool
 	// Queue of jobs to be processed.
-	jobs []batchJob[A, B]
+	jobs []*batchJob[A, B]
+	// Weight of each priority class, and the credit each has accrued
+	// towards its next pick, when weighted priority is enabled.
+	weights map[int]int
+	credits map[int]int
+	// Write-ahead log recording accepted jobs until they complete, if
+	// configured.
+	wal WALWriter[A]
+	// Function that runs processing tasks in place of new goroutines, if
+	// configured.
+	executor func(task func())
+	// Pool of workers that run processing tasks when concurrency is
+	// limited.
+	pool *workerPool
+	// Function called when the queue becomes active or inactive, at most
+	// once per interval, if configured.
+	onActivity       func(active bool)
+	activityInterval time.Duration
+	// Last activity reported, and when it was reported.
+	reportedActive bool
+	lastReport     time.Time
+	// Timer that reports the activity once the interval has passed.
+	activityTimer *time.Timer
+	// Mutex held while reporting activity, so reports are in order.
+	reportMu sync.Mutex
+	// Function deriving the idempotency key of each batch passed to the
+	// batch processor, if configured.
+	batchKey func([]Job[A]) string
+	// Function run against a fraction of jobs to inject faults when
+	// chaos testing, if configured.
+	faultInjector func(A) error
+	faultFraction float64
+	// Function applied to the data of each batch before it is processed,
+	// if configured.
+	batchTransform func([]A) []A
+	// Fraction of jobs sampled for logging, none when zero.
+	logFraction float64
+	// Function that merges an incoming job's data into a queued job's,
+	// if configured.
+	compactor func(existing, incoming A) (A, bool)
+	// Lock ordering producers by arrival, nil unless fair intake is
+	// enabled.
+	intake *ticketLock
 	// Ticker to control time-based batch processing.
 	ticker *time.Ticker
+	// Duration to pause flushing for after a batch fails, disabled when
+	// zero.
+	errorCooldown time.Duration
+	// Time until which flushing is paused after a failed batch.
+	cooldownUntil time.Time
+	// Timer that wakes the processing loop once the cooldown ends.
+	cooldownTimer *time.Timer
+	// Window after a size flush in which ticks are skipped, disabled when
+	// zero.
+	timerCoalesce time.Duration
+	// Time of the most recent size flush.
+	lastSizeFlush time.Time
+	// Time before its deadline within which a job is split into a batch
+	// of its own, disabled when zero.
+	deadlineSplit time.Duration
+	// Maximum time a job can wait on the queue before it is evicted,
+	// disabled when zero.
+	maxQueueWait time.Duration
+	// Timer that fires when the job at the head of the queue is due to be
+	// evicted.
+	queueWaitTimer *time.Timer
+	// Maximum estimated size in bytes of the queue before it is flushed
+	// early, disabled when zero.
+	memoryBudget int64
+	// Estimated size in bytes of a single queued job.
+	jobSize int64
+	// Maximum number of jobs that can be queued, unbounded when zero.
+	maxQueueSize int
+	// Maximum estimated size in bytes of the queued jobs, unbounded when
+	// zero, the function estimating the size of a job's data, and the
+	// estimated size of the jobs currently queued.
+	maxMemory   int64
+	sizeOf      func(A) int64
+	queuedBytes int64
+	// Batcher that receives jobs rejected due to a full queue.
+	overflow *Batcher[A, B]
+	// Condition signaled whenever jobs are removed from the queue.
+	drained *sync.Cond
+	// Function computing the key that queued jobs are deduplicated by.
+	dedupeKey func(A) any
+	// Callback invoked with the kept and dropped job when a duplicate
+	// job is collapsed into one already on the queue.
+	onDedupe func(kept Job[A], dropped Job[A])
+	// Queued jobs indexed by their deduplication key.
+	pending map[any]*batchJob[A, B]
+	// Identifier of the most recently flushed batch.
+	batchId uint64
+	// Channel notified after each batch has been processed.
+	flushed chan FlushInfo
+	// Buffer size of the flushed channel.
+	flushedBuffer int
+	// Maximum number of times a job is attempted before its error is
+	// delivered.
+	maxAttempts int
+	// Delay between attempts of a failed job.
+	retryBackoff time.Duration
+	// Function computing the key that results are cached by.
+	cacheKey func(A) any
+	// Cache of results of successfully processed jobs.
+	cache *lruCache[B]
+	// Whether jobs are processed by the dry run stub rather than the
+	// processor.
+	dryRun bool
+	// Function producing results in dry run mode, zero values are
+	// delivered when nil.
+	dryRunStub func(A) B
+	// Function invoked with the outcome of every completed job.
+	resultHandler func(Job[A], B, error)
+	// Capacity the queue is allocated with whenever it is emptied.
+	queueCapacity int
+	// Tracks batches that have been flushed but not finished processing.
+	active sync.WaitGroup
+	// Counters reported by Stats.
+	stats counters
+	// Outcomes of the most recently completed jobs, and the thresholds
+	// the health reported by Health is judged against.
+	recentErrors     errorWindow
+	healthThresholds HealthThresholds
+	// Whether time spent waiting for and holding the mutex is measured.
+	lockMetrics bool
+	// Time the mutex was last acquired, when lock metrics are enabled.
+	lockAcquired time.Time
+	// Age a job must reach before it is flushed by the ticker, the ticker
+	// flushes the entire queue when zero.
+	maxAge time.Duration
 
 	mu sync.Mutex
 }
 
 // NewBatcher constructs a new Batcher configured with the given processor,
-// frequency and batch size.
-func NewBatcher[A any, B any](processor func(A) B, frequency time.Duration, batchSize int) *Batcher[A, B] {
-	return &Batcher[A, B]{
-		processor:      processor,
+// frequency and batch size, along with any additional options.
+func NewBatcher[A any, B any](processor func(A) B, frequency time.Duration, batchSize int, opts ...Option[A, B]) *Batcher[A, B] {
+	fallible := func(data A) (B, error) {
+		return processor(data), nil
+	}
+
+	return NewFallibleBatcher(fallible, frequency, batchSize, opts...)
+}
+
+// NewFallibleBatcher constructs a new Batcher like NewBatcher, but with a
+// processor that can fail. Errors returned by the processor are delivered
+// to the JobResult of the job, after any configured retries.
+func NewFallibleBatcher[A any, B any](processor func(A) (B, error), frequency time.Duration, batchSize int, opts ...Option[A, B]) *Batcher[A, B] {
+	b := newBatcher(frequency, batchSize, opts...)
+	b.processor = processor
+
+	return b
+}
+
+// newBatcher constructs a Batcher without a processor, which must be set
+// by the caller before the Batcher is used.
+func newBatcher[A any, B any](frequency time.Duration, batchSize int, opts ...Option[A, B]) *Batcher[A, B] {
+	b := &Batcher[A, B]{
 		batchSize:      batchSize,
 		frequency:      frequency,
 		shuttingDown:   false,
 		shutdownSignal: make(chan bool, 1),
-		jobs:           []batchJob[A, B]{},
+		done:           make(chan struct{}),
+		aborted:        make(chan struct{}),
+		wake:           make(chan struct{}, 1),
+		idle:           make(chan struct{}, 1),
 		ticker:         time.NewTicker(frequency),
+		pending:        map[any]*batchJob[A, B]{},
+		flushedBuffer:  defaultFlushedBuffer,
+
+		healthThresholds: defaultHealthThresholds(frequency),
 	}
+
+	b.drained = sync.NewCond(&b.mu)
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	b.flushed = make(chan FlushInfo, b.flushedBuffer)
+
+	if b.idleTimeout > 0 {
+		b.idleTimer = time.AfterFunc(b.idleTimeout, b.notifyIdle)
+		b.idleTimer.Stop()
+	}
+	if b.onActivity != nil {
+		b.activityTimer = time.AfterFunc(0, b.reportActivity)
+		b.activityTimer.Stop()
+	}
+	if b.maxQueueWait > 0 {
+		b.queueWaitTimer = time.AfterFunc(b.maxQueueWait, b.expireQueued)
+		b.queueWaitTimer.Stop()
+	}
+	if b.errorCooldown > 0 {
+		b.cooldownTimer = time.AfterFunc(b.errorCooldown, b.notifyWake)
+		b.cooldownTimer.Stop()
+	}
+	b.jobs = make([]*batchJob[A, B], 0, b.queueCapacity)
+
+	return b
 }
 
 // AddJob adds the submitted job to the queue of the Batcher to be processed.
 // An error is returned if the Batcher is in the process of shutting down,
-// and is thus not able to accept new jobs.
+// and is thus not able to accept new jobs, or if its queue is full.
 func (b *Batcher[A, B]) AddJob(job Job[A]) (*JobResult[B], error) {
+	result := &JobResult[B]{}
+
+	if err := b.AddJobInto(job, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// AddJobContext adds the submitted job to the queue like AddJob, carrying
+// the context with the job so that request scoped values, such as trace
+// ids, are available from Job.Context at completion. The context's error is
+// returned without queuing the job if the context is already done.
+func (b *Batcher[A, B]) AddJobContext(ctx context.Context, job Job[A]) (*JobResult[B], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	job.ctx = ctx
+
+	return b.AddJob(job)
+}
+
+// Resubmit adds the job that produced the given result back onto the queue
+// to be processed again, returning a new result for it. This allows failed
+// jobs to be manually retried, such as after investigation by an operator.
+//
+// The result must have completed and been read with Get, otherwise
+// ErrResultInUse is returned. ErrCannotResubmit is returned if the result
+// does not belong to a job submitted to a Batcher of this type, such as a
+// result returned by MapResult. Resubmitting to a Batcher that is shutting
+// down fails with ErrShuttingDown, as for AddJob.
+//
+// The job is rebuilt from its Id, Data and Priority, without the context it
+// was added with by AddJobContext, so that a job abandoned because its
+// context ended can still be retried. Use AddJobContext directly to retry
+// with a new context.
+func (b *Batcher[A, B]) Resubmit(jr *JobResult[B]) (*JobResult[B], error) {
+	jr.mu.Lock()
+	read, job := jr.out != nil, jr.job
+	jr.mu.Unlock()
+
+	if !read {
+		return nil, ErrResultInUse
+	}
+
+	resubmit, ok := job.(Job[A])
+	if !ok {
+		return nil, ErrCannotResubmit
+	}
+
+	// Rebuild the job without the context it was submitted with, which
+	// has likely ended by the time the job is retried.
+	return b.AddJob(Job[A]{Id: resubmit.Id, Data: resubmit.Data, Priority: resubmit.Priority})
+}
+
+// AddJobInto adds the submitted job to the queue of the Batcher, delivering
+// its output to the caller-provided result rather than allocating a new one.
+// This allows callers to pool JobResults in allocation sensitive code.
+//
+// The result is reset before the job is queued, so a result must not be
+// reused until its previous job has completed and been read with Get.
+// ErrResultInUse is returned if the result is nil or still pending.
+func (b *Batcher[A, B]) AddJobInto(job Job[A], result *JobResult[B]) error {
+	if result == nil || result.pending() {
+		return ErrResultInUse
+	}
+
+	return b.add(job, result, nil)
+}
+
+// AddJobChan adds the submitted job to the queue of the Batcher, sending its
+// result on the given channel, such as one already used in a select loop,
+// rather than returning a JobResult. The same channel can be given for any
+// number of jobs, with each result identified by its JobId.
+//
+// Sending the result blocks the processing of the job's batch, and any
+// Shutdown waiting on it, until it is received, so the channel should be
+// buffered or received from promptly. In
+// particular, a result served from the result cache is sent before
+// AddJobChan returns, so the channel must have room for it if caching is
+// enabled and AddJobChan is called from the receiving goroutine.
+func (b *Batcher[A, B]) AddJobChan(job Job[A], ch chan<- Result[B]) error {
+	return b.add(job, &JobResult[B]{}, ch)
+}
+
+// add queues the job, delivering its result to the JobResult and also
+// sending it on out if it is not nil.
+func (b *Batcher[A, B]) add(job Job[A], result *JobResult[B], out chan<- Result[B]) error {
+	if b.intake != nil {
+		// Hold the intake ticket until the mutex is acquired, so that
+		// producers acquire the mutex in the order they arrived.
+		b.intake.lock()
+		b.lock()
+		b.intake.unlock()
+	} else {
+		b.lock()
+	}
+
+	// Check for shutdown while holding the lock, so the job is either
+	// drained by the shutdown or rejected.
 	if b.shuttingDown {
-		return nil, errors.New("failed to add job; batcher is shutting down")
+		b.unlock()
+		return ErrShuttingDown
 	}
 
-	ch := make(chan B, 1)
-	newJob := batchJob[A, B]{job: &job, retCh: ch}
+	if b.sealed {
+		b.unlock()
+		return ErrSealed
+	}
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
+	if b.requireStart && !b.started {
+		b.unlock()
+		return ErrNotStarted
+	}
+
+	ch := make(chan outcome[B], 1)
+	newJob := &batchJob[A, B]{job: &job, retCh: ch, out: out, enqueued: time.Now(), sampled: b.sample()}
+
+	if b.cache != nil {
+		newJob.cacheKey = b.cacheKey(job.Data)
+
+		// Complete the job immediately with the cached result if one
+		// exists, rather than queuing it.
+		if val, ok := b.cache.get(newJob.cacheKey); ok {
+			result.reset(job, job.Id, ch)
+			b.stats.submitted.Add(1)
+			b.stats.cacheHits.Add(1)
+
+			b.unlock()
+
+			b.deliver(newJob, val, nil)
+			b.stats.completed.Add(1)
+
+			return nil
+		}
+	}
+
+	if b.dedupeKey != nil {
+		newJob.key = b.dedupeKey(job.Data)
+
+		// Collapse the job into a queued job with the same key, the
+		// first job wins and its result is shared with the duplicate.
+		if existing, ok := b.pending[newJob.key]; ok {
+			if err := b.appendWAL(job); err != nil {
+				b.unlock()
+				return err
+			}
+
+			existing.merged = append(existing.merged, newJob)
+			kept := *existing.job
+			result.reset(job, job.Id, ch)
+			b.stats.submitted.Add(1)
+			b.countCacheMiss()
+
+			b.unlock()
+
+			if b.onDedupe != nil {
+				b.onDedupe(kept, job)
+			}
+
+			return nil
+		}
+	}
+
+	if b.compactor != nil {
+		if compacted, err := b.compact(newJob); compacted || err != nil {
+			if err == nil {
+				result.reset(job, job.Id, ch)
+			}
+
+			b.unlock()
+
+			return err
+		}
+	}
+
+	if b.maxMemory > 0 {
+		newJob.size = b.sizeOf(job.Data)
+
+		if b.queuedBytes+newJob.size > b.maxMemory {
+			b.unlock()
+			return ErrMemoryLimit
+		}
+	}
+
+	if b.maxQueueSize > 0 && len(b.jobs) >= b.maxQueueSize {
+		b.unlock()
+
+		// Route the job to the overflow batcher if one is configured,
+		// the result is delivered from there to the same JobResult.
+		if b.overflow != nil {
+			return b.overflow.add(job, result, out)
+		}
+
+		return ErrQueueFull
+	}
+
+	defer b.unlock()
+
+	if err := b.appendWAL(job); err != nil {
+		return err
+	}
+
+	if b.dedupeKey != nil {
+		b.pending[newJob.key] = newJob
+	}
+
+	result.reset(job, job.Id, ch)
+	b.stats.submitted.Add(1)
+	b.countCacheMiss()
+
+	// Flush the job straight away if it is the first after an idle gap,
+	// as there is no load to batch it with.
+	if b.flushFirstAfter > 0 {
+		if len(b.jobs) == 0 && newJob.enqueued.Sub(b.lastAdded) >= b.flushFirstAfter {
+			b.flushFirst = true
+		}
+
+		b.lastAdded = newJob.enqueued
+	}
 
 	b.jobs = append(b.jobs, newJob)
+	b.queuedBytes += newJob.size
+
+	if newJob.sampled {
+		slog.Info("microbatcher: job queued", "job_id", job.Id, "queued", len(b.jobs))
+	}
 
-	return &JobResult[B]{JobId: job.Id, ch: ch, data: nil}, nil
+	if len(b.jobs) == 1 {
+		b.activityChanged()
+	}
+
+	// Start timing the queue wait of the new head of the queue.
+	if b.queueWaitTimer != nil && len(b.jobs) == 1 {
+		b.queueWaitTimer.Reset(b.maxQueueWait)
+	}
+
+	// Restart the idle timeout now that a new job has arrived.
+	if b.idleTimer != nil {
+		b.idleTimer.Reset(b.idleTimeout)
+	}
+
+	// Wake the processing loop if the queue needs to be flushed.
+	if len(b.jobs) >= b.batchSize || b.overMemoryBudget() || b.flushFirst {
+		b.notifyWake()
+	}
+
+	return nil
 }
 
 // Start begins the processing of jobs by the Batcher, generally run as a
-// goroutine.
+// goroutine. Start returns immediately if the Batcher has already been
+// shut down.
 func (b *Batcher[A, B]) Start() {
+	if b.markStarted() {
+		b.run()
+	}
+}
+
+// StartAsync begins the processing of jobs by the Batcher in a new
+// goroutine. Unlike running Start as a goroutine, the Batcher is marked as
+// started before StartAsync returns, so jobs can be added immediately when
+// WithRequireStart is used.
+func (b *Batcher[A, B]) StartAsync() {
+	if b.markStarted() {
+		go b.run()
+	}
+}
+
+// markStarted records that the Batcher has been started, returning false
+// if it has already been shut down.
+func (b *Batcher[A, B]) markStarted() bool {
+	b.lock()
+	defer b.unlock()
+
+	if b.shuttingDown {
+		return false
+	}
+
+	b.started = true
+
+	return true
+}
+
+// run processes jobs until the Batcher shuts down.
+func (b *Batcher[A, B]) run() {
 	// Start the ticker based processing.
 	go b.startTicker()
 
-	// Start batch size processing.
+	// Start batch size processing, woken whenever a job is added that
+	// may require the queue to be flushed.
 	for {
-		switch {
-		case b.shuttingDown:
-			b.mu.Lock()
-			defer b.mu.Unlock()
+		select {
+		case <-b.done:
+			b.shutdownSignal <- true
 
-			// Process all remaining jobs on the queue if any exist.
-			if len(b.jobs) > 0 {
-				b.processBatch(b.jobs)
+			return
+		case <-b.wake:
+			b.lock()
 
-				b.jobs = []batchJob[A, B]{}
+			// Leave the queue to be drained by the shutdown once it has
+			// begun, or to accumulate while cooling down after a failed
+			// batch.
+			if b.shuttingDown || b.coolingDown(time.Now()) {
+				b.unlock()
+				continue
 			}
 
-			b.shutdownSignal <- true
+			for len(b.jobs) > 0 && len(b.jobs) >= b.batchSize {
+				// Create slice of jobs to be processed and update
+				// job queue.
+				batchJobs := b.dequeue(b.batchSize)
 
-			return
-		case len(b.jobs) >= b.batchSize:
-			b.mu.Lock()
+				// Process the first batchSize jobs in the queue.
+				b.processBatch(batchJobs, FlushSize)
+				b.lastSizeFlush = time.Now()
 
-			// Create slice of jobs to be processed and update
-			// job queue.
-			batchJobs := b.jobs[0:b.batchSize]
-			b.jobs = b.jobs[b.batchSize:]
+				// Reset the ticker.
+				b.ticker.Reset(b.frequency)
+			}
 
-			// Process the first batchSize jobs in the queue.
-			b.processBatch(batchJobs)
+			if b.overMemoryBudget() {
+				// Flush the entire queue to bring its memory footprint
+				// back under budget.
+				b.processBatch(b.dequeue(len(b.jobs)), FlushMemory)
 
-			// Reset the ticker.
-			b.ticker.Reset(b.frequency)
+				b.ticker.Reset(b.frequency)
+			}
+
+			if b.flushFirst {
+				// Flush the first job after an idle gap, along with any
+				// that have joined it since, rather than waiting for the
+				// batch to fill.
+				b.flushFirst = false
+				b.processBatch(b.dequeue(len(b.jobs)), FlushFirst)
+
+				b.ticker.Reset(b.frequency)
+			}
 
 			// Release the mutex lock.
-			b.mu.Unlock()
+			b.unlock()
+		case <-b.idle:
+			b.lock()
+
+			if b.shuttingDown || b.coolingDown(time.Now()) {
+				b.unlock()
+				continue
+			}
+
+			// Flush the queue as no jobs have been added within the
+			// idle timeout.
+			b.processBatch(b.dequeue(len(b.jobs)), FlushIdle)
+
+			b.ticker.Reset(b.frequency)
+
+			b.unlock()
 		}
 	}
 }
 
+// notifyWake wakes the processing loop to check whether the queue needs to
+// be flushed.
+func (b *Batcher[A, B]) notifyWake() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// notifyIdle notifies the processing loop that the idle timeout has passed
+// without a new job being added.
+func (b *Batcher[A, B]) notifyIdle() {
+	select {
+	case b.idle <- struct{}{}:
+	default:
+	}
+}
+
+// Flush immediately processes every job on the queue, regardless of the
+// batch size or ticker. ErrShuttingDown is returned once the Batcher has
+// shut down, and ErrNotStarted if it requires Start to be called first.
+func (b *Batcher[A, B]) Flush() error {
+	b.lock()
+	defer b.unlock()
+
+	if b.shuttingDown {
+		return ErrShuttingDown
+	}
+
+	if b.requireStart && !b.started {
+		return ErrNotStarted
+	}
+
+	b.processBatch(b.dequeue(len(b.jobs)), FlushManual)
+
+	b.ticker.Reset(b.frequency)
+
+	return nil
+}
+
+// FlushAndWait flushes every job on the queue like Flush, then waits for
+// those jobs to complete, returning the errors of any that failed joined
+// with errors.Join, each wrapped with the id of its job. If the context is
+// done first, its error is returned, although the jobs continue to be
+// processed. This provides a checkpoint between pipeline stages.
+func (b *Batcher[A, B]) FlushAndWait(ctx context.Context) error {
+	b.lock()
+
+	if b.shuttingDown {
+		b.unlock()
+		return ErrShuttingDown
+	}
+
+	if b.requireStart && !b.started {
+		b.unlock()
+		return ErrNotStarted
+	}
+
+	batch := b.dequeue(len(b.jobs))
+	completed := b.processBatch(batch, FlushManual)
+
+	b.ticker.Reset(b.frequency)
+
+	b.unlock()
+
+	if completed == nil {
+		return nil
+	}
+
+	select {
+	case <-completed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return errors.Join(jobErrors(batch)...)
+}
+
+// PeekBatch returns the data of the jobs that would make up the next batch
+// flushed by size, without removing or processing them. This is the first
+// batchSize jobs on the queue, or those selected by weighted priority if it
+// is enabled. The data is copied, so the returned slice can be
+// modified freely, although values referenced by the data are shared.
+func (b *Batcher[A, B]) PeekBatch() []A {
+	b.lock()
+	defer b.unlock()
+
+	n := min(b.batchSize, len(b.jobs))
+	next := b.jobs[:n]
+
+	if b.weights != nil && n < len(b.jobs) {
+		// Select from a copy of the weighted state, so that peeking does
+		// not affect the composition of later batches.
+		next, _ = b.selectWeighted(n, maps.Clone(b.credits))
+	}
+
+	data := make([]A, len(next))
+	for i, job := range next {
+		data[i] = job.job.Data
+	}
+
+	return data
+}
+
+// Seal stops the Batcher from accepting new jobs, which are rejected with
+// ErrSealed, while the jobs already queued continue to be flushed as normal
+// until the queue is empty. Unlike Shutdown, the processing loops keep
+// running, so the Batcher can be unsealed to accept jobs again. WaitUntilBelow
+// with a watermark of one can be used to wait for the queue to drain. A
+// sealed Batcher can still be shut down, and sealing has no effect once it
+// has been.
+func (b *Batcher[A, B]) Seal() {
+	b.lock()
+	defer b.unlock()
+
+	b.sealed = true
+}
+
+// Unseal allows a sealed Batcher to accept new jobs again. It has no effect
+// if the Batcher is not sealed, and does not reverse Shutdown.
+func (b *Batcher[A, B]) Unseal() {
+	b.lock()
+	defer b.unlock()
+
+	b.sealed = false
+}
+
+// Sealed reports whether the Batcher is sealed.
+func (b *Batcher[A, B]) Sealed() bool {
+	b.lock()
+	defer b.unlock()
+
+	return b.sealed
+}
+
 // Shutdown triggers the graceful shutdown of the Batcher, flushing all remaining
-// jobs from the queue before ceasing to process.
+// jobs from the queue before ceasing to process. The remaining jobs are flushed
+// immediately, regardless of the batch size or ticker, in the order
+// configured by WithShutdownOrder. Shutdown blocks until
+// every flushed job has completed and delivered its result, after which the
+// Flushed channel is closed. Subsequent calls have no effect.
 func (b *Batcher[A, B]) Shutdown() {
+	b.shutdownOnce.Do(func() {
+		b.shutdown(context.Background())
+	})
+}
+
+// Close gracefully shuts down the Batcher as Shutdown does, allowing it to
+// be managed as an io.Closer. It is safe to call multiple times, and even
+// if Start was never called. The errors of the jobs that failed while being
+// drained on shutdown are returned joined with errors.Join, each wrapped
+// with the id of its job, along with any fatal error that triggered the
+// shutdown and the context error if a drain by ShutdownContext was cut
+// short. Every call returns the same error.
+func (b *Batcher[A, B]) Close() error {
+	b.Shutdown()
+
+	b.lock()
+	defer b.unlock()
+
+	return b.shutdownErr
+}
+
+// shutdown stops the Batcher, draining the remaining jobs in the shutdown
+// order. Without a deadline on the context they are flushed all at once,
+// otherwise they are drained a batch at a time until the context is done.
+func (b *Batcher[A, B]) shutdown(ctx context.Context) {
+	b.lock()
+
 	b.shuttingDown = true
+	started := b.started
+
+	// Cancel the batches still processing if the drain is cut short, but
+	// otherwise let them run to completion.
+	stop := context.AfterFunc(ctx, b.abort)
+	defer stop()
+
+	// Process all remaining jobs on the queue if any exist, unless the
+	// drain can be cut short.
+	var drained []*batchJob[A, B]
+	if ctx.Done() == nil {
+		drained = b.dequeueForShutdown(len(b.jobs))
+		b.processBatch(drained, FlushShutdown)
+	}
+
+	b.unlock()
+
+	// Stop the processing loops.
+	close(b.done)
+	b.ticker.Stop()
+
+	if b.idleTimer != nil {
+		b.idleTimer.Stop()
+	}
+
+	if b.cooldownTimer != nil {
+		b.cooldownTimer.Stop()
+	}
+
+	if b.queueWaitTimer != nil {
+		b.queueWaitTimer.Stop()
+	}
+
+	// Only wait for the processing loop if it was started.
+	if started {
+		<-b.shutdownSignal
+	}
 
-	<-b.shutdownSignal
 	close(b.shutdownSignal)
+
+	// Drain any jobs left for a shutdown with a context, now that the
+	// processing loops can no longer flush them out of order.
+	more, abandoned := b.drain(ctx)
+	drained = append(drained, more...)
+
+	// Wait for in-flight batches so their results and stats are
+	// complete before the Batcher is torn down.
+	b.active.Wait()
+
+	// Record the errors of the drain to be returned by Close.
+	errs := jobErrors(drained)
+	if abandoned != nil {
+		errs = append(errs, abandoned)
+	}
+
+	b.lock()
+	if b.fatalErr != nil {
+		errs = append(errs, fmt.Errorf("shut down by fatal error from job %d: %w", b.fatalJob.Id, b.fatalErr))
+	}
+	b.shutdownErr = errors.Join(errs...)
+	b.unlock()
+
+	// Stop the workers now that there is nothing left to process.
+	b.lock()
+	pool := b.pool
+	b.unlock()
+
+	if pool != nil {
+		pool.close()
+	}
+
+	// Report the queue becoming inactive before returning, rather than
+	// after the debounce interval.
+	if b.activityTimer != nil {
+		b.activityTimer.Stop()
+		b.reportActivity()
+	}
+
+	close(b.flushed)
 }
 
-func (b *Batcher[A, B]) startTicker() {
-	for {
-		<-b.ticker.C
+// WaitUntilBelow blocks until the number of queued jobs falls below n, or
+// the context is done, in which case the context's error is returned. This
+// allows producers to pause submission until the Batcher has caught up.
+// ErrInvalidWatermark is returned if n is not positive, as the queue can
+// never fall below it.
+func (b *Batcher[A, B]) WaitUntilBelow(ctx context.Context, n int) error {
+	if n <= 0 {
+		return ErrInvalidWatermark
+	}
+
+	// Wake the waiter below when the context is done so the context
+	// error can be returned.
+	stop := context.AfterFunc(ctx, func() {
 		b.mu.Lock()
-		b.processBatch(b.jobs)
-		b.jobs = []batchJob[A, B]{}
-		b.mu.Unlock()
+		defer b.mu.Unlock()
+
+		b.drained.Broadcast()
+	})
+	defer stop()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for len(b.jobs) >= n {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		b.drained.Wait()
+	}
+
+	return nil
+}
+
+// dequeue removes and returns up to n jobs from the front of the queue,
+// waking any callers waiting on the queue to drain. The mutex must be held.
+func (b *Batcher[A, B]) dequeue(n int) []*batchJob[A, B] {
+	n = min(n, len(b.jobs))
+
+	if b.weights != nil && n < len(b.jobs) {
+		return b.dequeueWeighted(n)
+	}
+
+	return b.dequeueHead(n)
+}
+
+// dequeueHead removes and returns up to n jobs from the head of the queue,
+// regardless of weighted priority. The mutex must be held.
+func (b *Batcher[A, B]) dequeueHead(n int) []*batchJob[A, B] {
+	n = min(n, len(b.jobs))
+
+	batch := b.jobs[:n]
+	if n == len(b.jobs) {
+		b.jobs = make([]*batchJob[A, B], 0, b.queueCapacity)
+	} else {
+		b.jobs = b.jobs[n:]
 	}
+
+	b.release(batch)
+
+	return batch
 }
 
-func (b *Batcher[A, B]) processBatch(batch []batchJob[A, B]) {
+// dequeueOverdue removes and returns the jobs that have been queued for at
+// least maxAge, leaving fresher jobs on the queue to accumulate towards a
+// full batch. The mutex must be held.
+func (b *Batcher[A, B]) dequeueOverdue(now time.Time) []*batchJob[A, B] {
+	overdue := []*batchJob[A, B]{}
+	fresh := make([]*batchJob[A, B], 0, b.queueCapacity)
+
+	for _, job := range b.jobs {
+		if now.Sub(job.enqueued) >= b.maxAge {
+			overdue = append(overdue, job)
+		} else {
+			fresh = append(fresh, job)
+		}
+	}
+
+	b.jobs = fresh
+	b.release(overdue)
+
+	return overdue
+}
+
+// release updates the state of the Batcher for jobs that have been removed
+// from the queue. The mutex must be held.
+func (b *Batcher[A, B]) release(batch []*batchJob[A, B]) {
 	for _, job := range batch {
-		go b.processJob(job)
+		b.queuedBytes -= job.size
+	}
+
+	if b.dedupeKey != nil {
+		for _, job := range batch {
+			delete(b.pending, job.key)
+		}
+	}
+
+	if len(b.jobs) == 0 {
+		b.activityChanged()
+
+		// The first job after an idle gap has been flushed with the rest.
+		b.flushFirst = false
+	}
+
+	b.drained.Broadcast()
+}
+
+// overMemoryBudget reports whether the estimated size of the queued jobs
+// has reached the configured memory budget. Jobs in flight are not
+// counted, as flushing the queue cannot free the memory they hold. The
+// mutex must be held.
+func (b *Batcher[A, B]) overMemoryBudget() bool {
+	if b.memoryBudget <= 0 || len(b.jobs) == 0 {
+		return false
+	}
+
+	return int64(len(b.jobs))*b.jobSize >= b.memoryBudget
+}
+
+func (b *Batcher[A, B]) startTicker() {
+	for {
+		select {
+		case <-b.done:
+			return
+		case now := <-b.ticker.C:
+			b.tick(now)
+		}
+	}
+}
+
+// tick flushes the queue when the ticker fires, unless the tick falls
+// within the coalesce window of a size flush.
+func (b *Batcher[A, B]) tick(now time.Time) {
+	b.lock()
+	defer b.unlock()
+
+	if b.timerCoalesce > 0 && now.Sub(b.lastSizeFlush) < b.timerCoalesce {
+		return
+	}
+
+	if b.shuttingDown || b.coolingDown(now) {
+		return
+	}
+
+	// Only flush the overdue jobs if a maximum age is configured,
+	// otherwise flush the entire queue.
+	if b.maxAge > 0 {
+		b.processBatch(b.dequeueOverdue(now), FlushTimer)
+	} else {
+		b.processBatch(b.dequeue(len(b.jobs)), FlushTimer)
+	}
+}
+
+// processBatch processes each job in the batch, returning a channel that
+// is closed once all of them have completed, or nil if the batch is empty.
+// Jobs close to their deadline are split into a batch of their own if
+// deadline splitting is enabled. The mutex must be held.
+func (b *Batcher[A, B]) processBatch(batch []*batchJob[A, B], reason FlushReason) <-chan struct{} {
+	if b.deadlineSplit > 0 {
+		urgent, rest := b.splitUrgent(batch, time.Now())
+
+		if len(urgent) > 0 && len(rest) > 0 {
+			// Dispatch the urgent jobs first, so they are not held up
+			// behind the rest.
+			return joinCompleted(b.dispatch(urgent, reason), b.dispatch(rest, reason))
+		}
+	}
+
+	return b.dispatch(batch, reason)
+}
+
+// dispatch processes each job in the batch, notifying listeners on the
+// Flushed channel once all of them have completed. The returned channel is
+// closed at the same time, and is nil if the batch is empty. The mutex must
+// be held.
+func (b *Batcher[A, B]) dispatch(batch []*batchJob[A, B], reason FlushReason) <-chan struct{} {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	b.batchId++
+	b.active.Add(1)
+	b.stats.inFlight.Add(int64(len(batch)))
+
+	info := FlushInfo{BatchId: b.batchId, Size: len(batch), Reason: reason, JobIds: make([]int, len(batch))}
+	for i, job := range batch {
+		info.JobIds[i] = job.job.Id
+
+		if job.sampled {
+			slog.Info("microbatcher: job flushed", "job_id", job.job.Id, "batch_id", info.BatchId, "reason", reason.String())
+		}
 	}
+	start := time.Now()
+
+	var wg sync.WaitGroup
+
+	if b.batchTransform != nil && !b.transform(batch) {
+		// Fail the batch, as its results could not be routed back to its
+		// jobs.
+		wg.Add(1)
+
+		b.execute(func() {
+			defer wg.Done()
+			b.fail(batch, ErrBatchSizeMismatch)
+		})
+	} else if b.streamProcessor != nil {
+		// Process the entire batch with a single call, delivering results
+		// as they are emitted.
+		wg.Add(1)
+
+		b.execute(func() {
+			defer wg.Done()
+			b.processStream(batch)
+		})
+	} else if b.batchProcessor != nil {
+		// Process the entire batch with a single call.
+		wg.Add(1)
+
+		b.execute(func() {
+			defer wg.Done()
+			b.processBulk(batch)
+		})
+	} else {
+		wg.Add(len(batch))
+
+		for _, job := range batch {
+			b.execute(func() {
+				defer wg.Done()
+				b.processJob(job)
+			})
+		}
+	}
+
+	completed := make(chan struct{})
+
+	go func() {
+		defer b.active.Done()
+		defer close(completed)
+
+		wg.Wait()
+
+		if b.errorCooldown > 0 {
+			b.recordOutcome(batch)
+		}
+
+		info.Duration = time.Since(start)
+		b.notifyFlushed(info)
+	}()
+
+	return completed
+}
+
+// execute runs the processing task with the configured executor or worker
+// pool, or in a new goroutine if there is neither. The mutex must be held.
+func (b *Batcher[A, B]) execute(task func()) {
+	if b.executor != nil {
+		b.executor(task)
+		return
+	}
+
+	if b.pool != nil {
+		b.pool.submit(task)
+		return
+	}
+
+	go task()
 }
 
-func (b *Batcher[A, B]) processJob(job batchJob[A, B]) {
-	res := b.processor(job.job.Data)
-	job.retCh <- res
+// processJob processes a single job with its context. A job whose context
+// was cancelled while it was queued is completed with the context's error
+// instead.
+func (b *Batcher[A, B]) processJob(job *batchJob[A, B]) {
+	ctx := job.job.Context()
+	if err := ctx.Err(); err != nil {
+		var zero B
+		b.complete(job, zero, err)
+
+		return
+	}
+
+	start := time.Now()
+	res, err := b.process(ctx, job.job.Data)
+	job.duration = time.Since(start)
+
+	b.complete(job, res, err)
+}
+
+// complete records the outcome of a processed job, delivering it to the
+// job and any duplicates collapsed into it.
+func (b *Batcher[A, B]) complete(job *batchJob[A, B], res B, err error) {
+	job.err = err
+
+	// Start shutting down before the result is delivered, so that callers
+	// reacting to the fatal error find new jobs already rejected.
+	if b.isFatal(err) {
+		b.fatal(*job.job, err)
+	}
+
+	// Record the outcome before it is delivered, so it is reflected by
+	// Health as soon as the result can be read.
+	b.recentErrors.record(isFailure(err))
+
+	if err == nil && b.cache != nil {
+		b.cache.add(job.cacheKey, res)
+	}
+
+	b.deliver(job, res, err)
+
+	for _, dupe := range job.merged {
+		dupe.duration = job.duration
+		b.deliver(dupe, res, err)
+	}
+
+	if b.wal != nil && !job.unprocessed {
+		b.wal.MarkDone(*job.job)
+
+		for _, dupe := range job.merged {
+			b.wal.MarkDone(*dupe.job)
+		}
+	}
+
+	b.stats.completed.Add(uint64(1 + len(job.merged)))
+	b.stats.inFlight.Add(-1)
+}
+
+// deliver sends the outcome of the job to its JobResult and to the result
+// handler, if one is configured.
+func (b *Batcher[A, B]) deliver(job *batchJob[A, B], res B, err error) {
+	job.retCh <- outcome[B]{val: res, err: err, duration: job.duration}
+
+	if job.out != nil {
+		job.out <- Result[B]{JobId: job.job.Id, Value: res, Err: err, Duration: job.duration}
+	}
+
+	if job.sampled {
+		slog.Info("microbatcher: job completed", "job_id", job.job.Id, "error", err)
+	}
+
+	if b.resultHandler != nil {
+		b.resultHandler(*job.job, res, err)
+	}
 }
@@ -1,7 +1,12 @@
 package microbatcher
 
 import (
+	"context"
+	"errors"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -41,13 +46,13 @@ func TestBatcherBatchSize(t *testing.T) {
 	go b.Start()
 	defer b.Shutdown()
 
-	aData := resA.Get()
-	if aData != "HELLO WORLD" {
+	aData, err := resA.Get()
+	if err != nil || aData != "HELLO WORLD" {
 		t.Error("failed to process job A correctly")
 	}
 
-	bData := resB.Get()
-	if bData != "FOOBAR" {
+	bData, err := resB.Get()
+	if err != nil || bData != "FOOBAR" {
 		t.Error("failed to process job B correctly")
 	}
 
@@ -98,13 +103,13 @@ func TestBatcherTimout(t *testing.T) {
 		t.Error("failed to add job B")
 	}
 
-	aStr := resA.Get()
-	if aStr != "HELLO WORLD" {
+	aStr, err := resA.Get()
+	if err != nil || aStr != "HELLO WORLD" {
 		t.Errorf("failed to process job 1 correctly")
 	}
 
-	bStr := resB.Get()
-	if bStr != "FOOBAR" {
+	bStr, err := resB.Get()
+	if err != nil || bStr != "FOOBAR" {
 		t.Errorf("failed to process job 1 correctly")
 	}
 
@@ -185,8 +190,8 @@ func TestBatcherShutdownClearsQueue(t *testing.T) {
 	// Allow time for processing.
 	time.Sleep(10 * time.Millisecond)
 
-	strD := resD.Get()
-	if strD != "JOB 4" {
+	strD, err := resD.Get()
+	if err != nil || strD != "JOB 4" {
 		t.Error("failed to process final job properly")
 	}
 
@@ -207,10 +212,735 @@ func TestBatcherJobResultReaccessingOutput(t *testing.T) {
 		t.Error("failed to add job 4")
 	}
 
-	strA := resA.Get()
-	reaccess := resA.Get()
+	strA, err := resA.Get()
+	if err != nil {
+		t.Error("failed to get result output")
+	}
 
-	if strA != reaccess {
+	reaccess, err := resA.Get()
+	if err != nil || strA != reaccess {
 		t.Error("reaccessing result output does not match")
 	}
 }
+
+func TestBatcherAddJobIntoReusesResult(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res := &JobResult[string]{}
+
+	err := b.AddJobInto(Job[string]{Id: 1, Data: "hello world"}, res)
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if str, err := res.Get(); err != nil || str != "HELLO WORLD" {
+		t.Error("failed to process job 1 correctly")
+	}
+
+	err = b.AddJobInto(Job[string]{Id: 2, Data: "foobar"}, res)
+	if err != nil {
+		t.Error("failed to reuse result for job 2")
+	}
+
+	if res.JobId != 2 {
+		t.Error("reused result has incorrect job id")
+	}
+
+	if str, err := res.Get(); err != nil || str != "FOOBAR" {
+		t.Error("failed to process job 2 correctly")
+	}
+}
+
+func TestBatcherAddJobIntoRejectsPendingResult(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	res := &JobResult[string]{}
+
+	err := b.AddJobInto(Job[string]{Id: 1, Data: "hello world"}, res)
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	err = b.AddJobInto(Job[string]{Id: 2, Data: "foobar"}, res)
+	if err != ErrResultInUse {
+		t.Error("reused a result that was still pending")
+	}
+
+	err = b.AddJobInto(Job[string]{Id: 3, Data: "baz"}, nil)
+	if err != ErrResultInUse {
+		t.Error("accepted a nil result")
+	}
+}
+
+func TestBatcherJobResultZeroValue(t *testing.T) {
+	b := NewBatcher(func(in string) string { return "" }, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "hello world"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	str, err := res.Get()
+	if err != nil || str != "" {
+		t.Error("zero value result was not returned")
+	}
+}
+
+func TestBatcherWaitUntilBelow(t *testing.T) {
+	b := NewBatcher(uppercaseString, 10*time.Millisecond, 10)
+
+	for i := 1; i <= 3; i++ {
+		_, err := b.AddJob(Job[string]{Id: i, Data: "foobar"})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	go b.Start()
+	defer b.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := b.WaitUntilBelow(ctx, 1); err != nil {
+		t.Error("failed to wait for the queue to drain")
+	}
+}
+
+func TestBatcherWaitUntilBelowInvalidWatermark(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	for _, n := range []int{0, -1} {
+		if err := b.WaitUntilBelow(context.Background(), n); err != ErrInvalidWatermark {
+			t.Errorf("waited for an invalid watermark of %d", n)
+		}
+	}
+}
+
+func TestBatcherWaitUntilBelowContextDone(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	_, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := b.WaitUntilBelow(ctx, 1); err != context.DeadlineExceeded {
+		t.Error("wait did not return the context error")
+	}
+}
+
+func TestMapResult(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	mapped := MapResult(res, func(in string) int { return len(in) })
+	if mapped.JobId != 1 {
+		t.Error("mapped result has incorrect job id")
+	}
+
+	length, err := mapped.Get()
+	if err != nil || length != 6 {
+		t.Error("failed to map job result correctly")
+	}
+}
+
+func TestMapResultPropagatesError(t *testing.T) {
+	processor := func(in string) (string, error) {
+		return "", errTransient
+	}
+
+	b := NewFallibleBatcher(processor, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	mapped := MapResult(res, func(in string) int {
+		t.Error("mapping function applied to a failed result")
+		return len(in)
+	})
+
+	if _, err := mapped.Get(); err != errTransient {
+		t.Error("mapped result did not propagate the error")
+	}
+}
+
+func TestBatcherShutdownFlushesPartialBatchPromptly(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	go b.Start()
+
+	results := []*JobResult[string]{}
+	for i := 1; i <= 5; i++ {
+		res, err := b.AddJob(Job[string]{Id: i, Data: "foobar"})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+
+		results = append(results, res)
+	}
+
+	start := time.Now()
+	b.Shutdown()
+
+	if time.Since(start) > 50*time.Millisecond {
+		t.Error("shutdown did not flush the partial batch promptly")
+	}
+
+	info, ok := <-b.Flushed()
+	if !ok || info.Size != 5 || info.Reason != FlushShutdown {
+		t.Error("partial batch was not flushed by shutdown")
+	}
+
+	for i, res := range results {
+		str, err := res.Get()
+		if err != nil || str != "FOOBAR" {
+			t.Errorf("failed to process job %d correctly", i)
+		}
+	}
+}
+
+func TestBatcherCloseIsIdempotent(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	go b.Start()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if err := b.Close(); err != nil {
+		t.Error("failed to close batcher")
+	}
+
+	if err := b.Close(); err != nil {
+		t.Error("failed to close batcher a second time")
+	}
+
+	str, err := res.Get()
+	if err != nil || str != "FOOBAR" {
+		t.Error("close did not flush remaining jobs")
+	}
+}
+
+func TestBatcherCloseReturnsDrainErrors(t *testing.T) {
+	processor := func(in string) (string, error) {
+		if in == "" {
+			return "", errTransient
+		}
+
+		return strings.ToUpper(in), nil
+	}
+
+	b := NewFallibleBatcher(processor, FIVE_MINUTES, 10)
+
+	go b.Start()
+
+	for i, str := range []string{"foobar", "", "baz"} {
+		if _, err := b.AddJob(Job[string]{Id: i, Data: str}); err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	err := b.Close()
+	if !errors.Is(err, errTransient) || !strings.Contains(err.Error(), "job 1") || strings.Contains(err.Error(), "job 0") {
+		t.Errorf("close did not return the error of the failed job: %v", err)
+	}
+
+	if again := b.Close(); again == nil || again.Error() != err.Error() {
+		t.Error("close returned a different error a second time")
+	}
+}
+
+func TestBatcherCloseReturnsFatalError(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+
+	b := NewFallibleBatcher(failFatally(release), FIVE_MINUTES, 1, WithShutdownOnFatal[string, string]())
+
+	go b.Start()
+
+	res, err := b.AddJob(Job[string]{Id: 7, Data: "gone"})
+	if err != nil {
+		t.Error("failed to add job 7")
+	}
+
+	res.Get()
+
+	if err := b.Close(); !errors.Is(err, ErrFatal) || !strings.Contains(err.Error(), "job 7") {
+		t.Errorf("close did not return the fatal error: %v", err)
+	}
+}
+
+func TestBatcherCloseWithoutStart(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if err := b.Close(); err != nil {
+		t.Error("failed to close batcher that was never started")
+	}
+
+	str, err := res.Get()
+	if err != nil || str != "FOOBAR" {
+		t.Error("close did not flush remaining jobs")
+	}
+}
+
+type traceKey struct{}
+
+func TestBatcherAddJobContextPropagatesValues(t *testing.T) {
+	traces := make(chan any, 1)
+	handler := func(job Job[string], res string, err error) {
+		traces <- job.Context().Value(traceKey{})
+	}
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 1, WithResultHandler(handler))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), traceKey{}, "trace-1"))
+
+	res, err := b.AddJobContext(ctx, Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	// Values remain available after the context is cancelled, even though
+	// the cancelled job is no longer processed.
+	cancel()
+
+	if _, err := res.Get(); !errors.Is(err, context.Canceled) {
+		t.Error("cancelled job was processed")
+	}
+
+	if trace := <-traces; trace != "trace-1" {
+		t.Error("job context was not available to the result handler")
+	}
+}
+
+func TestBatcherAddJobContextDone(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := b.AddJobContext(ctx, Job[string]{Id: 1, Data: "foobar"}); err != context.Canceled {
+		t.Error("added job with a done context")
+	}
+}
+
+func TestBatcherFlush(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	b.Flush()
+
+	if str, err := res.Get(); err != nil || str != "FOOBAR" {
+		t.Error("flush did not process queued job")
+	}
+}
+
+func TestBatcherPeekBatch(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 2)
+	defer b.Shutdown()
+
+	if len(b.PeekBatch()) != 0 {
+		t.Error("peeked jobs on an empty queue")
+	}
+
+	addJobs(t, b, "hello world", "foobar", "baz")
+
+	peeked := b.PeekBatch()
+	if !slices.Equal(peeked, []string{"hello world", "foobar"}) {
+		t.Error("peeked batch did not hold the first batch size jobs")
+	}
+
+	// Modifying the peeked batch does not affect the queue.
+	peeked[0] = "modified"
+
+	if b.PeekBatch()[0] != "hello world" || b.Stats().Queued != 3 {
+		t.Error("peeking modified the queue")
+	}
+}
+
+func TestBatcherFlushAfterShutdown(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	go b.Start()
+	b.Shutdown()
+
+	if err := b.Flush(); !errors.Is(err, ErrShuttingDown) {
+		t.Error("flush after shutdown did not return an error")
+	}
+}
+
+func TestBatcherResubmit(t *testing.T) {
+	var calls atomic.Int32
+	processor := func(in string) (string, error) {
+		if calls.Add(1) == 1 {
+			return "", errors.New("downstream unavailable")
+		}
+
+		return strings.ToUpper(in), nil
+	}
+
+	b := NewFallibleBatcher(processor, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if _, err := b.Resubmit(res); err != ErrResultInUse {
+		t.Error("resubmitted a job that had not completed")
+	}
+
+	if _, err := res.Get(); err == nil {
+		t.Error("first attempt of job 1 did not fail")
+	}
+
+	retried, err := b.Resubmit(res)
+	if err != nil {
+		t.Error("failed to resubmit job 1")
+	}
+
+	str, err := retried.Get()
+	if err != nil || str != "FOOBAR" || retried.JobId != 1 {
+		t.Error("failed to reprocess job 1 correctly")
+	}
+
+	mapped := MapResult(retried, strings.ToLower)
+	if _, err := mapped.Get(); err != nil {
+		t.Error("failed to get mapped result")
+	}
+
+	if _, err := b.Resubmit(mapped); err != ErrCannotResubmit {
+		t.Error("resubmitted a result without a job")
+	}
+}
+
+func TestBatcherResubmitCancelledJob(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	res, err := b.AddJobContext(ctx, Job[string]{Id: 1, Data: "foobar", Priority: 2})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	cancel()
+	b.Flush()
+
+	if _, err := res.Get(); !errors.Is(err, context.Canceled) {
+		t.Error("cancelled job was processed")
+	}
+
+	retried, err := b.Resubmit(res)
+	if err != nil {
+		t.Error("failed to resubmit job 1")
+	}
+
+	b.Flush()
+
+	if str, err := retried.Get(); err != nil || str != "FOOBAR" || retried.JobId != 1 {
+		t.Error("resubmitted job was not processed without the cancelled context")
+	}
+}
+
+func TestBatcherSealStopsIntakeAndDrains(t *testing.T) {
+	b := NewBatcher(uppercaseString, ONE_MILLISECOND, 10)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	b.Seal()
+
+	if _, err := b.AddJob(Job[string]{Id: 2, Data: "foobar"}); !errors.Is(err, ErrSealed) {
+		t.Error("job was accepted by a sealed batcher")
+	}
+
+	// Jobs queued before sealing are still flushed.
+	if str, err := res.Get(); err != nil || str != "FOOBAR" {
+		t.Error("queued job was not flushed after sealing")
+	}
+
+	if err := b.WaitUntilBelow(context.Background(), 1); err != nil || !b.Sealed() {
+		t.Error("sealed batcher did not drain")
+	}
+
+	b.Unseal()
+
+	res, err = b.AddJob(Job[string]{Id: 3, Data: "foobar"})
+	if err != nil {
+		t.Fatal("job was rejected after unsealing")
+	}
+
+	if str, err := res.Get(); err != nil || str != "FOOBAR" {
+		t.Error("failed to process job after unsealing")
+	}
+}
+
+func TestBatcherSealAfterShutdown(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	go b.Start()
+
+	b.Seal()
+	b.Shutdown()
+	b.Unseal()
+
+	// Unsealing does not reverse the shutdown.
+	if _, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"}); !errors.Is(err, ErrShuttingDown) {
+		t.Error("job was accepted after shutdown")
+	}
+}
+
+func TestBatcherAddJobChan(t *testing.T) {
+	errBad := errors.New("bad input")
+	processor := func(in string) (string, error) {
+		if in == "" {
+			return "", errBad
+		}
+
+		return strings.ToUpper(in), nil
+	}
+
+	b := NewFallibleBatcher(processor, FIVE_MINUTES, 2)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	results := make(chan Result[string], 2)
+
+	if err := b.AddJobChan(Job[string]{Id: 1, Data: "foobar"}, results); err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if err := b.AddJobChan(Job[string]{Id: 2, Data: ""}, results); err != nil {
+		t.Error("failed to add job 2")
+	}
+
+	got := map[int]Result[string]{}
+	for range 2 {
+		select {
+		case res := <-results:
+			got[res.JobId] = res
+		case <-time.After(time.Second):
+			t.Fatal("result was not sent on the channel")
+		}
+	}
+
+	if got[1].Value != "FOOBAR" || got[1].Err != nil {
+		t.Error("failed to process job 1 correctly")
+	}
+
+	if got[2].Err != errBad {
+		t.Error("error of job 2 was not sent on the channel")
+	}
+}
+
+func TestBatcherGetResultReportsDuration(t *testing.T) {
+	processor := func(in string) string {
+		time.Sleep(20 * time.Millisecond)
+		return strings.ToUpper(in)
+	}
+
+	b := NewBatcher(processor, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	result := res.GetResult()
+	if result.JobId != 1 || result.Value != "FOOBAR" || result.Err != nil {
+		t.Error("failed to process job 1 correctly")
+	}
+
+	if result.Duration < 20*time.Millisecond {
+		t.Error("result did not report the time spent processing")
+	}
+}
+
+func TestBatcherGetContext(t *testing.T) {
+	b := NewBatcher(strings.ToUpper, FIVE_MINUTES, 2)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ONE_MILLISECOND)
+	defer cancel()
+
+	if _, err := res.GetContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("did not stop waiting when the context was done")
+	}
+
+	if _, err := b.AddJob(Job[string]{Id: 2, Data: "baz"}); err != nil {
+		t.Error("failed to add job 2")
+	}
+
+	if str, err := res.GetContext(context.Background()); err != nil || str != "FOOBAR" {
+		t.Error("result could not be read after the context was done")
+	}
+}
+
+func TestJobResultConcurrentAccess(t *testing.T) {
+	processor := func(in string) string {
+		time.Sleep(5 * time.Millisecond)
+		return strings.ToUpper(in)
+	}
+
+	b := NewBatcher(processor, FIVE_MINUTES, 2)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if _, ok := res.TryGet(); ok {
+		t.Error("result was available before the job completed")
+	}
+
+	var wg sync.WaitGroup
+	results := make([]Result[string], 30)
+
+	for i := range results {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			switch i % 3 {
+			case 0:
+				val, err := res.Get()
+				results[i] = Result[string]{JobId: res.JobId, Value: val, Err: err}
+			case 1:
+				results[i] = res.GetResult()
+			case 2:
+				for {
+					if result, ok := res.TryGet(); ok {
+						results[i] = result
+						return
+					}
+
+					time.Sleep(time.Millisecond)
+				}
+			}
+		}()
+	}
+
+	if _, err := b.AddJob(Job[string]{Id: 2, Data: "baz"}); err != nil {
+		t.Error("failed to add job 2")
+	}
+
+	wg.Wait()
+
+	expected := res.GetResult()
+	if expected.Value != "FOOBAR" || expected.Err != nil || expected.Duration < 5*time.Millisecond {
+		t.Error("failed to process job 1 correctly")
+	}
+
+	for i, result := range results {
+		if i%3 == 0 {
+			result.Duration = expected.Duration
+		}
+
+		if result != expected {
+			t.Errorf("reader %d saw an inconsistent result: %+v", i, result)
+		}
+	}
+}
+
+func TestMapResultConcurrentGet(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	var calls atomic.Int32
+	mapped := MapResult(res, func(in string) int {
+		calls.Add(1)
+		return len(in)
+	})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if length, err := mapped.Get(); err != nil || length != 6 {
+				t.Error("failed to map job result correctly")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Error("mapping function was applied more than once")
+	}
+}
@@ -0,0 +1,93 @@
+package microbatcher
+
+import (
+	"fmt"
+	"testing"
+)
+
+func identity(in int) int {
+	return in
+}
+
+func BenchmarkAddJob(b *testing.B) {
+	for _, capacity := range []int{0, 1024} {
+		b.Run(fmt.Sprintf("capacity=%d", capacity), func(b *testing.B) {
+			batcher := NewBatcher(identity, FIVE_MINUTES, b.N+1, WithQueueCapacity[int, int](capacity))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := batcher.AddJob(Job[int]{Id: i, Data: i}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFlush(b *testing.B) {
+	for _, batchSize := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("batch=%d", batchSize), func(b *testing.B) {
+			batcher := NewBatcher(identity, FIVE_MINUTES, batchSize, WithQueueCapacity[int, int](batchSize))
+			results := make([]*JobResult[int], batchSize)
+
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				for j := range results {
+					res, err := batcher.AddJob(Job[int]{Id: j, Data: j})
+					if err != nil {
+						b.Fatal(err)
+					}
+
+					results[j] = res
+				}
+				b.StartTimer()
+
+				batcher.mu.Lock()
+				batcher.processBatch(batcher.dequeue(batchSize), FlushSize)
+				batcher.mu.Unlock()
+
+				for _, res := range results {
+					if _, err := res.Get(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEndToEnd(b *testing.B) {
+	for _, batchSize := range []int{1, 10, 100} {
+		for _, parallelism := range []int{1, 8, 64} {
+			name := fmt.Sprintf("batch=%d/parallelism=%d", batchSize, parallelism)
+
+			b.Run(name, func(b *testing.B) {
+				batcher := NewBatcher(identity, ONE_MILLISECOND, batchSize, WithQueueCapacity[int, int](batchSize))
+
+				go batcher.Start()
+				defer batcher.Shutdown()
+
+				b.ReportAllocs()
+				b.SetParallelism(parallelism)
+				b.ResetTimer()
+
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						res, err := batcher.AddJob(Job[int]{Id: 1, Data: 1})
+						if err != nil {
+							b.Fatal(err)
+						}
+
+						if _, err := res.Get(); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package microbatcher
+
+import (
+	"context"
+	"time"
+)
+
+// NewBulkBatcher constructs a new Batcher like NewFallibleBatcher, but with
+// a processor that handles an entire batch in a single call, such as a bulk
+// write to a downstream service. The context passed to the processor is
+// only cancelled if a drain by ShutdownContext is cut short, so batches
+// flushed by a graceful shutdown are processed in full. The processor must
+// return one result per job, in the same order as the data it was given, otherwise every job in
+// the batch fails with ErrBatchSizeMismatch. An error returned by the
+// processor fails every job in the batch.
+func NewBulkBatcher[A any, B any](processor func(context.Context, []A) ([]B, error), frequency time.Duration, batchSize int, opts ...Option[A, B]) *Batcher[A, B] {
+	b := newBatcher(frequency, batchSize, opts...)
+	b.batchProcessor = processor
+
+	return b
+}
+
+// NewResourceBatcher constructs a new Batcher like NewBulkBatcher, with a
+// resource, such as a database connection, leased from a pool by acquire
+// for each batch. The resource is passed to the processor and released once
+// the batch has been processed. If the resource cannot be acquired, every
+// job in the batch fails with the error returned by acquire. Both acquire
+// and the processor are given the batch's context, which is cancelled if a
+// drain by ShutdownContext is cut short.
+//
+// This is a constructor rather than an option because the resource type R
+// ties acquire to the processor's signature, which an Option[A, B] cannot
+// express.
+func NewResourceBatcher[A any, B any, R any](
+	acquire func(context.Context) (R, func(), error),
+	processor func(context.Context, R, []A) ([]B, error),
+	frequency time.Duration,
+	batchSize int,
+	opts ...Option[A, B],
+) *Batcher[A, B] {
+	bulk := func(ctx context.Context, data []A) ([]B, error) {
+		resource, release, err := acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		return processor(ctx, resource, data)
+	}
+
+	return NewBulkBatcher(bulk, frequency, batchSize, opts...)
+}
+
+// processBulk processes the batch with a single call to the batch processor,
+// completing each job with its corresponding result.
+func (b *Batcher[A, B]) processBulk(batch []*batchJob[A, B]) {
+	batch = b.live(batch)
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := b.batchContext()
+	defer cancel()
+
+	ctx = b.withBatchKey(ctx, batch)
+
+	data := make([]A, len(batch))
+	for i, job := range batch {
+		data[i] = job.job.Data
+	}
+
+	start := time.Now()
+	res, err := b.processBatchData(ctx, data)
+	duration := time.Since(start)
+
+	if err == nil && len(res) != len(batch) {
+		err = ErrBatchSizeMismatch
+	}
+
+	for i, job := range batch {
+		var val B
+		if err == nil {
+			val = res[i]
+		}
+
+		job.duration = duration
+		b.complete(job, val, err)
+	}
+}
+
+// live returns the jobs of the batch that are still wanted. Jobs whose
+// context was cancelled while they were queued are completed with the
+// context's error and left out of the batch.
+func (b *Batcher[A, B]) live(batch []*batchJob[A, B]) []*batchJob[A, B] {
+	live := batch[:0:0]
+	for _, job := range batch {
+		if err := job.job.Context().Err(); err != nil {
+			var zero B
+			b.complete(job, zero, err)
+
+			continue
+		}
+
+		live = append(live, job)
+	}
+
+	return live
+}
+
+// batchContext returns the context for processing a batch, which is
+// cancelled when a shutdown drain is cut short or the returned cancel is
+// called.
+func (b *Batcher[A, B]) batchContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		select {
+		case <-b.aborted:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
@@ -0,0 +1,224 @@
+package microbatcher
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func uppercaseStrings(ctx context.Context, in []string) ([]string, error) {
+	out := make([]string, len(in))
+	for i, str := range in {
+		out[i] = strings.ToUpper(str)
+	}
+
+	return out, nil
+}
+
+func addJobs(t *testing.T, b *Batcher[string, string], data ...string) []*JobResult[string] {
+	results := []*JobResult[string]{}
+
+	for i, str := range data {
+		res, err := b.AddJob(Job[string]{Id: i, Data: str})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+
+		results = append(results, res)
+	}
+
+	return results
+}
+
+func TestBulkBatcherProcessesBatchInOneCall(t *testing.T) {
+	var calls atomic.Int32
+	processor := func(ctx context.Context, in []string) ([]string, error) {
+		calls.Add(1)
+		return uppercaseStrings(ctx, in)
+	}
+
+	b := NewBulkBatcher(processor, FIVE_MINUTES, 3)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	results := addJobs(t, b, "hello world", "foobar", "baz")
+	expected := []string{"HELLO WORLD", "FOOBAR", "BAZ"}
+
+	for i, res := range results {
+		str, err := res.Get()
+		if err != nil || str != expected[i] {
+			t.Errorf("failed to process job %d correctly", i)
+		}
+	}
+
+	if calls.Load() != 1 {
+		t.Error("batch was not processed in a single call")
+	}
+}
+
+func TestBulkBatcherResultCountMismatch(t *testing.T) {
+	processor := func(ctx context.Context, in []string) ([]string, error) {
+		return []string{"FOOBAR"}, nil
+	}
+
+	b := NewBulkBatcher(processor, FIVE_MINUTES, 2)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	for i, res := range addJobs(t, b, "foobar", "baz") {
+		if _, err := res.Get(); err != ErrBatchSizeMismatch {
+			t.Errorf("job %d did not fail with a size mismatch", i)
+		}
+	}
+}
+
+func TestResourceBatcherReleasesResource(t *testing.T) {
+	var acquired, released atomic.Int32
+	acquire := func(ctx context.Context) (string, func(), error) {
+		acquired.Add(1)
+		return "conn", func() { released.Add(1) }, nil
+	}
+
+	processor := func(ctx context.Context, conn string, in []string) ([]string, error) {
+		if conn != "conn" {
+			t.Error("resource was not passed to the processor")
+		}
+
+		return uppercaseStrings(ctx, in)
+	}
+
+	b := NewResourceBatcher(acquire, processor, FIVE_MINUTES, 2)
+
+	go b.Start()
+
+	for i, res := range addJobs(t, b, "foobar", "baz") {
+		if _, err := res.Get(); err != nil {
+			t.Errorf("failed to process job %d", i)
+		}
+	}
+
+	b.Shutdown()
+
+	if acquired.Load() != 1 || released.Load() != 1 {
+		t.Error("resource was not acquired and released once for the batch")
+	}
+}
+
+func TestResourceBatcherAcquireFailure(t *testing.T) {
+	errAcquire := errors.New("pool exhausted")
+	acquire := func(ctx context.Context) (string, func(), error) {
+		return "", nil, errAcquire
+	}
+
+	processor := func(ctx context.Context, conn string, in []string) ([]string, error) {
+		t.Error("processor called without a resource")
+		return in, nil
+	}
+
+	b := NewResourceBatcher(acquire, processor, FIVE_MINUTES, 2)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	for i, res := range addJobs(t, b, "foobar", "baz") {
+		if _, err := res.Get(); err != errAcquire {
+			t.Errorf("job %d did not fail with the acquire error", i)
+		}
+	}
+}
+
+func TestBulkBatcherCompletesBatchesOnShutdown(t *testing.T) {
+	release := make(chan struct{})
+	processor := func(ctx context.Context, in []string) ([]string, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-release:
+			return uppercaseStrings(ctx, in)
+		}
+	}
+
+	b := NewBulkBatcher(processor, FIVE_MINUTES, 2)
+
+	go b.Start()
+
+	// The first two jobs are in flight and the last is drained on shutdown.
+	results := addJobs(t, b, "hello world", "foobar", "baz")
+
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		b.Shutdown()
+	}()
+
+	// Wait for the shutdown to begin before releasing the processor.
+	for b.Health() != Unhealthy {
+		time.Sleep(ONE_MILLISECOND)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	for i, res := range results {
+		if _, err := res.Get(); err != nil {
+			t.Errorf("job %d was not processed during a graceful shutdown: %v", i, err)
+		}
+	}
+
+	<-shutdown
+}
+
+func TestBulkBatcherCancelsContextWhenDrainCutShort(t *testing.T) {
+	processor := func(ctx context.Context, in []string) ([]string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	b := NewBulkBatcher(processor, FIVE_MINUTES, 1)
+
+	go b.Start()
+
+	results := addJobs(t, b, "foobar")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := b.ShutdownContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("shutdown was not cut short")
+	}
+
+	if _, err := results[0].Get(); !errors.Is(err, context.Canceled) {
+		t.Error("batch context was not cancelled when the drain was cut short")
+	}
+
+	for range b.Flushed() {
+	}
+}
+
+func TestBulkBatcherGetResultSharesDuration(t *testing.T) {
+	processor := func(ctx context.Context, in []string) ([]string, error) {
+		time.Sleep(20 * time.Millisecond)
+		return uppercaseStrings(ctx, in)
+	}
+
+	b := NewBulkBatcher(processor, FIVE_MINUTES, 2)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	results := addJobs(t, b, "hello world", "foobar")
+
+	first, second := results[0].GetResult(), results[1].GetResult()
+	if first.Err != nil || second.Err != nil {
+		t.Error("failed to process the batch")
+	}
+
+	if first.Duration < 20*time.Millisecond || first.Duration != second.Duration {
+		t.Error("jobs did not share the duration of the batch call")
+	}
+}
@@ -0,0 +1,80 @@
+package microbatcher
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a fixed size cache of job results that evicts the least
+// recently used entry when full. It has its own mutex so that processing
+// goroutines can populate it without contending on the Batcher's mutex.
+type lruCache[B any] struct {
+	maxEntries int
+	// Entries ordered from most to least recently used.
+	order *list.List
+	// Entries in the order list indexed by their key.
+	entries map[any]*list.Element
+
+	mu sync.Mutex
+}
+
+type cacheEntry[B any] struct {
+	key any
+	val B
+}
+
+func newLRUCache[B any](maxEntries int) *lruCache[B] {
+	return &lruCache[B]{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    map[any]*list.Element{},
+	}
+}
+
+// get returns the cached value for the key, marking it as the most
+// recently used.
+func (c *lruCache[B]) get(key any) (B, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		var zero B
+		return zero, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return elem.Value.(*cacheEntry[B]).val, true
+}
+
+// add caches the value for the key, evicting the least recently used
+// entry if the cache is over capacity.
+func (c *lruCache[B]) add(key any, val B) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry[B]).val = val
+		c.order.MoveToFront(elem)
+
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry[B]{key: key, val: val})
+
+	if c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry[B]).key)
+	}
+}
+
+// countCacheMiss records a cache miss for a job accepted by the Batcher, if
+// result caching is enabled. Misses are only counted once a job is accepted,
+// so that a job routed to an overflow batcher is not counted by both.
+func (b *Batcher[A, B]) countCacheMiss() {
+	if b.cache != nil {
+		b.stats.cacheMisses.Add(1)
+	}
+}
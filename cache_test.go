@@ -0,0 +1,63 @@
+package microbatcher
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLRUCache[string](2)
+
+	c.add("a", "A")
+	c.add("b", "B")
+
+	// Access a so that b becomes the least recently used.
+	if val, ok := c.get("a"); !ok || val != "A" {
+		t.Error("failed to get cached value")
+	}
+
+	c.add("c", "C")
+
+	if _, ok := c.get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+
+	if _, ok := c.get("a"); !ok {
+		t.Error("recently used entry was evicted")
+	}
+}
+
+func TestBatcherResultCacheLRU(t *testing.T) {
+	var calls atomic.Int32
+	processor := func(in string) string {
+		calls.Add(1)
+		return strings.ToUpper(in)
+	}
+
+	b := NewBatcher(processor, FIVE_MINUTES, 1, WithResultCacheLRU[string, string](strings.ToLower, 10))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	for i, data := range []string{"foobar", "FooBar"} {
+		res, err := b.AddJob(Job[string]{Id: i, Data: data})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+
+		str, err := res.Get()
+		if err != nil || str != "FOOBAR" {
+			t.Errorf("failed to process job %d correctly", i)
+		}
+	}
+
+	if calls.Load() != 1 {
+		t.Error("cached result was processed again")
+	}
+
+	stats := b.Stats()
+	if stats.CacheHits != 1 || stats.CacheMisses != 1 {
+		t.Error("incorrect cache stats")
+	}
+}
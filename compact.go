@@ -0,0 +1,42 @@
+package microbatcher
+
+// compact merges the new job into the most recently queued job that the
+// compactor accepts, reporting whether it was merged. The merged job shares
+// the result of the job it was merged into. The mutex must be held.
+func (b *Batcher[A, B]) compact(newJob *batchJob[A, B]) (bool, error) {
+	for i := len(b.jobs) - 1; i >= 0; i-- {
+		existing := b.jobs[i]
+
+		data, ok := b.compactor(existing.job.Data, newJob.job.Data)
+		if !ok {
+			continue
+		}
+
+		if err := b.appendWAL(*newJob.job); err != nil {
+			return false, err
+		}
+
+		existing.job.Data = data
+		existing.merged = append(existing.merged, newJob)
+
+		// Account for the size of the merged data in place of the
+		// original.
+		if b.maxMemory > 0 {
+			size := b.sizeOf(data)
+			b.queuedBytes += size - existing.size
+			existing.size = size
+		}
+
+		// Cache the result under the merged data it is produced from.
+		if b.cache != nil {
+			existing.cacheKey = b.cacheKey(data)
+		}
+
+		b.stats.submitted.Add(1)
+		b.countCacheMiss()
+
+		return true, nil
+	}
+
+	return false, nil
+}
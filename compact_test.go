@@ -0,0 +1,68 @@
+package microbatcher
+
+import (
+	"slices"
+	"testing"
+)
+
+// increment adds to the counter with the given name.
+type increment struct {
+	name string
+	by   int
+}
+
+// mergeIncrements combines increments to the same counter.
+func mergeIncrements(existing, incoming increment) (increment, bool) {
+	if existing.name != incoming.name {
+		return existing, false
+	}
+
+	return increment{name: existing.name, by: existing.by + incoming.by}, true
+}
+
+func TestBatcherCompactorMergesJobs(t *testing.T) {
+	processor := func(in increment) int {
+		return in.by
+	}
+
+	b := NewBatcher(processor, FIVE_MINUTES, 10, WithCompactor[increment, int](mergeIncrements))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	results := []*JobResult[int]{}
+	for i, inc := range []increment{{"a", 1}, {"b", 2}, {"a", 3}} {
+		res, err := b.AddJob(Job[increment]{Id: i + 1, Data: inc})
+		if err != nil {
+			t.Errorf("failed to add job %d", i+1)
+		}
+
+		results = append(results, res)
+	}
+
+	if b.Stats().Queued != 2 {
+		t.Error("mergeable jobs were queued separately")
+	}
+
+	b.Flush()
+
+	info := <-b.Flushed()
+	if !slices.Equal(info.JobIds, []int{1, 2}) {
+		t.Errorf("batch did not hold the compacted jobs, got %v", info.JobIds)
+	}
+
+	// The merged jobs share the result of the compacted job.
+	for i, expected := range []int{4, 2, 4} {
+		if val, err := results[i].Get(); err != nil || val != expected {
+			t.Errorf("job %d received %d, expected %d", i+1, val, expected)
+		}
+
+		if results[i].JobId != i+1 {
+			t.Errorf("job %d result did not keep its id", i+1)
+		}
+	}
+
+	if stats := b.Stats(); stats.Submitted != 3 {
+		t.Error("merged job was not counted as submitted")
+	}
+}
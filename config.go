@@ -0,0 +1,65 @@
+package microbatcher
+
+import "time"
+
+// Config is a snapshot of the configuration of a Batcher.
+type Config struct {
+	// Maximum number of jobs in a batch flushed by size.
+	BatchSize int
+	// Interval at which the queue is flushed.
+	Frequency time.Duration
+	// Maximum number of jobs that can be queued, unbounded when zero.
+	MaxQueueSize int
+	// Maximum number of processing tasks run at once, unbounded when zero.
+	Concurrency int
+}
+
+// Config returns the current configuration of the Batcher, reflecting any
+// changes made since it was constructed, such as by SetConcurrency.
+func (b *Batcher[A, B]) Config() Config {
+	b.lock()
+	defer b.unlock()
+
+	config := Config{
+		BatchSize:    b.batchSize,
+		Frequency:    b.frequency,
+		MaxQueueSize: b.maxQueueSize,
+	}
+
+	if b.pool != nil {
+		b.pool.mu.Lock()
+		config.Concurrency = b.pool.size
+		b.pool.mu.Unlock()
+	}
+
+	return config
+}
+
+// SetConcurrency changes the maximum number of processing tasks run at
+// once, as configured by WithConcurrency, while the Batcher is running.
+// Growing starts new workers immediately, while shrinking lets excess
+// workers finish their current task before they exit, so no work is lost.
+// If concurrency was not limited, it is from the next batch on.
+// ErrInvalidConcurrency is returned if n is not positive, and
+// ErrShuttingDown once the Batcher has shut down.
+func (b *Batcher[A, B]) SetConcurrency(n int) error {
+	if n < 1 {
+		return ErrInvalidConcurrency
+	}
+
+	b.lock()
+	defer b.unlock()
+
+	if b.shuttingDown {
+		return ErrShuttingDown
+	}
+
+	if b.pool == nil {
+		b.pool = newWorkerPool(n)
+		return nil
+	}
+
+	b.pool.resize(n)
+
+	return nil
+}
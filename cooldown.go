@@ -0,0 +1,45 @@
+package microbatcher
+
+import "time"
+
+// coolingDown reports whether flushing is paused after a failed batch. The
+// mutex must be held.
+func (b *Batcher[A, B]) coolingDown(now time.Time) bool {
+	return now.Before(b.cooldownUntil)
+}
+
+// recordOutcome starts the cooldown if any job in the processed batch
+// failed, or ends it early if the batch succeeded. Jobs abandoned because
+// their context was done are not counted as failures.
+func (b *Batcher[A, B]) recordOutcome(batch []*batchJob[A, B]) {
+	failed := false
+
+	for _, job := range batch {
+		if isFailure(job.err) {
+			failed = true
+			break
+		}
+	}
+
+	b.lock()
+	defer b.unlock()
+
+	if b.shuttingDown {
+		return
+	}
+
+	if failed {
+		b.cooldownUntil = time.Now().Add(b.errorCooldown)
+		b.cooldownTimer.Reset(b.errorCooldown)
+
+		return
+	}
+
+	if !b.cooldownUntil.IsZero() {
+		// A successful probe ends the cooldown, so flush anything that
+		// accumulated during it.
+		b.cooldownUntil = time.Time{}
+		b.cooldownTimer.Stop()
+		b.notifyWake()
+	}
+}
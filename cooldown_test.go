@@ -0,0 +1,95 @@
+package microbatcher
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failFirst returns a processor that fails its first call only.
+func failFirst() func(string) (string, error) {
+	var calls atomic.Int32
+
+	return func(in string) (string, error) {
+		if calls.Add(1) == 1 {
+			return "", errTransient
+		}
+
+		return strings.ToUpper(in), nil
+	}
+}
+
+func TestBatcherErrorCooldownPausesFlushing(t *testing.T) {
+	cooldown := 100 * time.Millisecond
+	b := NewFallibleBatcher(failFirst(), FIVE_MINUTES, 1, WithErrorCooldown[string, string](cooldown))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	if _, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"}); err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	<-b.Flushed()
+	failed := time.Now()
+
+	res, err := b.AddJob(Job[string]{Id: 2, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 2")
+	}
+
+	if str, err := res.Get(); err != nil || str != "FOOBAR" {
+		t.Error("failed to process job 2 after the cooldown")
+	}
+
+	if time.Since(failed) < cooldown/2 {
+		t.Error("job was flushed during the cooldown")
+	}
+}
+
+func TestBatcherErrorCooldownEndsOnSuccessfulProbe(t *testing.T) {
+	b := NewFallibleBatcher(failFirst(), FIVE_MINUTES, 1, WithErrorCooldown[string, string](FIVE_MINUTES))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	if _, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"}); err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	<-b.Flushed()
+
+	if _, err := b.AddJob(Job[string]{Id: 2, Data: "foobar"}); err != nil {
+		t.Error("failed to add job 2")
+	}
+
+	// Allow time for a flush that should not happen.
+	time.Sleep(10 * time.Millisecond)
+
+	if b.Stats().Queued != 1 {
+		t.Fatal("job was flushed during the cooldown")
+	}
+
+	// A manual flush probes the downstream, ending the cooldown.
+	if err := b.Flush(); err != nil {
+		t.Error("failed to flush during the cooldown")
+	}
+
+	<-b.Flushed()
+
+	res, err := b.AddJob(Job[string]{Id: 3, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 3")
+	}
+
+	select {
+	case <-b.Flushed():
+	case <-time.After(time.Second):
+		t.Fatal("flushing did not resume after a successful probe")
+	}
+
+	if str, err := res.Get(); err != nil || str != "FOOBAR" {
+		t.Error("failed to process job 3 correctly")
+	}
+}
@@ -0,0 +1,32 @@
+package microbatcher
+
+import "time"
+
+// splitUrgent splits the batch into the jobs whose context deadline is
+// within the deadline split threshold, and the rest, keeping their order.
+func (b *Batcher[A, B]) splitUrgent(batch []*batchJob[A, B], now time.Time) (urgent, rest []*batchJob[A, B]) {
+	for _, job := range batch {
+		if deadline, ok := job.job.Context().Deadline(); ok && deadline.Sub(now) <= b.deadlineSplit {
+			urgent = append(urgent, job)
+		} else {
+			rest = append(rest, job)
+		}
+	}
+
+	return urgent, rest
+}
+
+// joinCompleted returns a channel that is closed once both channels are
+// closed.
+func joinCompleted(a, b <-chan struct{}) <-chan struct{} {
+	joined := make(chan struct{})
+
+	go func() {
+		defer close(joined)
+
+		<-a
+		<-b
+	}()
+
+	return joined
+}
@@ -0,0 +1,87 @@
+package microbatcher
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatcherDeadlineSplitProcessesUrgentJobsSeparately(t *testing.T) {
+	var mu sync.Mutex
+	calls := [][]string{}
+
+	processor := func(ctx context.Context, in []string) ([]string, error) {
+		mu.Lock()
+		calls = append(calls, slices.Clone(in))
+		mu.Unlock()
+
+		return uppercaseStrings(ctx, in)
+	}
+
+	b := NewBulkBatcher(processor, FIVE_MINUTES, 10, WithDeadlineSplit[string, string](time.Minute))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	relaxed := addJobs(t, b, "hello world", "foobar")
+
+	urgent, err := b.AddJobContext(ctx, Job[string]{Id: 2, Data: "baz"})
+	if err != nil {
+		t.Error("failed to add job 2")
+	}
+
+	b.Flush()
+
+	// The urgent job is processed in a batch of its own. Batches are
+	// reported as they complete, so in either order.
+	batches := [][]int{(<-b.Flushed()).JobIds, (<-b.Flushed()).JobIds}
+	slices.SortFunc(batches, func(x, y []int) int { return len(x) - len(y) })
+
+	if !slices.Equal(batches[0], []int{2}) || !slices.Equal(batches[1], []int{0, 1}) {
+		t.Errorf("urgent job was not split into its own batch, got %v", batches)
+	}
+
+	if str, err := urgent.Get(); err != nil || str != "BAZ" {
+		t.Error("failed to process the urgent job correctly")
+	}
+
+	for i, expected := range []string{"HELLO WORLD", "FOOBAR"} {
+		if str, err := relaxed[i].Get(); err != nil || str != expected {
+			t.Errorf("failed to process job %d correctly", i)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(calls) != 2 {
+		t.Error("split batches were not processed in separate calls")
+	}
+}
+
+func TestBatcherDeadlineSplitKeepsBatchWithoutUrgentJobs(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithDeadlineSplit[string, string](time.Millisecond))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	addJobs(t, b, "hello world")
+
+	if _, err := b.AddJobContext(ctx, Job[string]{Id: 1, Data: "foobar"}); err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	b.Flush()
+
+	if info := <-b.Flushed(); info.Size != 2 {
+		t.Error("batch was split without an urgent job")
+	}
+}
@@ -23,16 +23,24 @@ func main() {
 		panic(err)
 	}
 
-	strA := resA.Get()
-	if strA.err != nil {
+	strA, err := resA.Get()
+	if err != nil {
 		panic(err)
 	}
 
-	strB := resB.Get()
 	if strA.err != nil {
+		panic(strA.err)
+	}
+
+	strB, err := resB.Get()
+	if err != nil {
 		panic(err)
 	}
 
+	if strB.err != nil {
+		panic(strB.err)
+	}
+
 	fmt.Println(strA.output)
 	fmt.Println(strB.output)
 
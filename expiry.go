@@ -0,0 +1,43 @@
+package microbatcher
+
+import "time"
+
+// expireQueued evicts the jobs that have waited on the queue for longer
+// than the maximum queue wait, completing them with ErrQueueTimeout, then
+// schedules the next eviction for the new head of the queue. Jobs are
+// removed from the queue before they are completed, so an evicted job is
+// never also processed, and are not marked done in the write-ahead log.
+func (b *Batcher[A, B]) expireQueued() {
+	b.lock()
+
+	if b.shuttingDown {
+		b.unlock()
+		return
+	}
+
+	now := time.Now()
+
+	// The queue is in the order jobs were added, so the expired jobs are
+	// at its head.
+	n := 0
+	for n < len(b.jobs) && now.Sub(b.jobs[n].enqueued) >= b.maxQueueWait {
+		n++
+	}
+
+	expired := b.jobs[:n]
+	b.jobs = b.jobs[n:]
+	b.release(expired)
+	b.stats.inFlight.Add(int64(len(expired)))
+
+	if len(b.jobs) > 0 {
+		b.queueWaitTimer.Reset(b.maxQueueWait - now.Sub(b.jobs[0].enqueued))
+	}
+
+	b.unlock()
+
+	var zero B
+	for _, job := range expired {
+		job.unprocessed = true
+		b.complete(job, zero, ErrQueueTimeout)
+	}
+}
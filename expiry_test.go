@@ -0,0 +1,57 @@
+package microbatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatcherMaxQueueWaitEvictsJobs(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithMaxQueueWait[string, string](20*time.Millisecond))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	resA, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	resB, err := b.AddJob(Job[string]{Id: 2, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 2")
+	}
+
+	if _, err := resA.Get(); err != ErrQueueTimeout {
+		t.Error("job 1 was not evicted from the queue")
+	}
+
+	if b.Stats().Queued != 1 {
+		t.Error("job 2 was evicted before its queue wait elapsed")
+	}
+
+	if _, err := resB.Get(); err != ErrQueueTimeout {
+		t.Error("job 2 was not evicted from the queue")
+	}
+
+	if b.Stats().Queued != 0 {
+		t.Error("evicted jobs remained on the queue")
+	}
+}
+
+func TestBatcherMaxQueueWaitSparesFlushedJobs(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 1, WithMaxQueueWait[string, string](20*time.Millisecond))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if str, err := res.Get(); err != nil || str != "FOOBAR" {
+		t.Error("flushed job was evicted")
+	}
+}
@@ -0,0 +1,79 @@
+package microbatcher
+
+import (
+	"context"
+	"time"
+)
+
+// FanOutBatcher is a Batcher whose processor expands each job into any
+// number of outputs, such as one input row mapping to several downstream
+// records. It can be used as a Batcher, with AddJob and AddJobContext
+// returning a FanOutResult for each job.
+type FanOutBatcher[A any, B any] struct {
+	*Batcher[A, []B]
+}
+
+// FanOutResult is the result of a job added to a FanOutBatcher. It can be
+// used as a JobResult, with GetAll returning the job's outputs.
+type FanOutResult[B any] struct {
+	*JobResult[[]B]
+}
+
+// NewFanOutBatcher constructs a new FanOutBatcher with a processor that
+// expands each job into any number of outputs, which are delivered
+// together, in the order returned by the processor, by GetAll on the job's
+// FanOutResult.
+//
+// The slice delivered is shared with any duplicate jobs collapsed into the
+// job and with later jobs served from the result cache, so it should not be
+// modified when deduplication or result caching is enabled.
+func NewFanOutBatcher[A any, B any](processor func(A) []B, frequency time.Duration, batchSize int, opts ...Option[A, []B]) *FanOutBatcher[A, B] {
+	return &FanOutBatcher[A, B]{NewBatcher(processor, frequency, batchSize, opts...)}
+}
+
+// NewFallibleFanOutBatcher constructs a new FanOutBatcher like
+// NewFanOutBatcher, but with a processor that can fail. An error fails the
+// whole expansion: GetAll returns the error without any outputs, even if
+// the processor returned some alongside it, after any configured retries.
+//
+// To report the outcome of each output individually instead, use an output
+// type that carries its own error, such as Result, and return a nil error
+// from the processor so that every output is delivered.
+func NewFallibleFanOutBatcher[A any, B any](processor func(A) ([]B, error), frequency time.Duration, batchSize int, opts ...Option[A, []B]) *FanOutBatcher[A, B] {
+	return &FanOutBatcher[A, B]{NewFallibleBatcher(processor, frequency, batchSize, opts...)}
+}
+
+// AddJob adds the submitted job to the queue like Batcher.AddJob, returning
+// a FanOutResult for its outputs.
+func (f *FanOutBatcher[A, B]) AddJob(job Job[A]) (*FanOutResult[B], error) {
+	res, err := f.Batcher.AddJob(job)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FanOutResult[B]{res}, nil
+}
+
+// AddJobContext adds the submitted job to the queue like
+// Batcher.AddJobContext, returning a FanOutResult for its outputs.
+func (f *FanOutBatcher[A, B]) AddJobContext(ctx context.Context, job Job[A]) (*FanOutResult[B], error) {
+	res, err := f.Batcher.AddJobContext(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FanOutResult[B]{res}, nil
+}
+
+// GetAll waits for the job to complete and returns every output it was
+// expanded into, in the order returned by the processor. If the job failed,
+// its error is returned without any outputs, as the expansion succeeds or
+// fails as a whole.
+func (r *FanOutResult[B]) GetAll() ([]B, error) {
+	outputs, err := r.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	return outputs, nil
+}
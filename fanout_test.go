@@ -0,0 +1,96 @@
+package microbatcher
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func splitWords(in string) []string {
+	return strings.Fields(in)
+}
+
+func TestFanOutBatcherDeliversEveryOutput(t *testing.T) {
+	b := NewFanOutBatcher(splitWords, FIVE_MINUTES, 2)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	resA, err := b.AddJob(Job[string]{Id: 1, Data: "hello world"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	resB, err := b.AddJob(Job[string]{Id: 2, Data: ""})
+	if err != nil {
+		t.Error("failed to add job 2")
+	}
+
+	if words, err := resA.GetAll(); err != nil || !slices.Equal(words, []string{"hello", "world"}) {
+		t.Error("outputs of job 1 were not delivered intact")
+	}
+
+	if words, err := resB.GetAll(); err != nil || len(words) != 0 {
+		t.Error("job 2 expanded into outputs")
+	}
+}
+
+func TestFanOutBatcherFailsWholeExpansion(t *testing.T) {
+	errPartial := errors.New("expansion interrupted")
+	processor := func(in string) ([]string, error) {
+		words := splitWords(in)
+		if len(words) > 1 {
+			return words[:1], errPartial
+		}
+
+		return words, nil
+	}
+
+	b := NewFallibleFanOutBatcher(processor, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "hello world"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if words, err := res.GetAll(); !errors.Is(err, errPartial) || words != nil {
+		t.Error("failed expansion did not deliver its error without outputs")
+	}
+}
+
+func TestFanOutBatcherPerOutputErrors(t *testing.T) {
+	processor := func(in string) []Result[int] {
+		outputs := []Result[int]{}
+		for i, field := range strings.Fields(in) {
+			n, err := strconv.Atoi(field)
+			outputs = append(outputs, Result[int]{JobId: i, Value: n, Err: err})
+		}
+
+		return outputs
+	}
+
+	b := NewFanOutBatcher(processor, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "1 two 3"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	outputs, err := res.GetAll()
+	if err != nil || len(outputs) != 3 {
+		t.Error("outputs with individual errors were not all delivered")
+		return
+	}
+
+	if outputs[0].Value != 1 || outputs[1].Err == nil || outputs[2].Value != 3 {
+		t.Error("outputs did not carry their individual outcomes")
+	}
+}
@@ -0,0 +1,39 @@
+package microbatcher
+
+import "errors"
+
+// fatal records the job whose error triggered a fatal shutdown and starts
+// shutting the Batcher down, unless it is already shutting down. New jobs
+// are rejected from this point, while the jobs already queued are drained
+// by the shutdown.
+func (b *Batcher[A, B]) fatal(job Job[A], err error) {
+	b.lock()
+	defer b.unlock()
+
+	if b.shuttingDown {
+		return
+	}
+
+	b.shuttingDown = true
+	b.fatalJob = job
+	b.fatalErr = err
+
+	// Shut down from a new goroutine, as Shutdown waits for the batch that
+	// this job belongs to.
+	go b.Shutdown()
+}
+
+// FatalJob returns the job whose error triggered the automatic shutdown of
+// the Batcher, along with the error, when WithShutdownOnFatal is used. The
+// error is nil if the Batcher has not been shut down by a fatal error.
+func (b *Batcher[A, B]) FatalJob() (Job[A], error) {
+	b.lock()
+	defer b.unlock()
+
+	return b.fatalJob, b.fatalErr
+}
+
+// isFatal reports whether the error should trigger a fatal shutdown.
+func (b *Batcher[A, B]) isFatal(err error) bool {
+	return b.shutdownOnFatal && errors.Is(err, ErrFatal)
+}
@@ -0,0 +1,92 @@
+package microbatcher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// failFatally returns a processor that fails fatally for "gone" once release
+// is closed, and uppercases any other data.
+func failFatally(release chan struct{}) func(string) (string, error) {
+	return func(in string) (string, error) {
+		if in == "gone" {
+			<-release
+			return "", fmt.Errorf("downstream removed: %w", ErrFatal)
+		}
+
+		return strings.ToUpper(in), nil
+	}
+}
+
+func TestBatcherShutdownOnFatal(t *testing.T) {
+	release := make(chan struct{})
+	b := NewFallibleBatcher(failFatally(release), FIVE_MINUTES, 2, WithShutdownOnFatal[string, string]())
+
+	go b.Start()
+	defer b.Shutdown()
+
+	results := []*JobResult[string]{}
+	for i, str := range []string{"gone", "foobar", "baz"} {
+		res, err := b.AddJob(Job[string]{Id: i, Data: str})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+
+		results = append(results, res)
+	}
+
+	close(release)
+
+	if _, err := results[0].Get(); !errors.Is(err, ErrFatal) {
+		t.Error("fatal error was not delivered")
+	}
+
+	if _, err := b.AddJob(Job[string]{Id: 3, Data: "foobar"}); !errors.Is(err, ErrShuttingDown) {
+		t.Error("job was accepted after a fatal error")
+	}
+
+	for range b.Flushed() {
+	}
+
+	if str, err := results[2].Get(); err != nil || str != "BAZ" {
+		t.Error("queued job was not drained after a fatal error")
+	}
+
+	if job, err := b.FatalJob(); job.Id != 0 || !errors.Is(err, ErrFatal) {
+		t.Error("job that triggered the shutdown was not reported")
+	}
+}
+
+func TestBatcherFatalIgnoredWithoutOption(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+
+	b := NewFallibleBatcher(failFatally(release), FIVE_MINUTES, 1, WithRetry[string, string](3, ONE_MILLISECOND))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "gone"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if _, err := res.Get(); !errors.Is(err, ErrFatal) {
+		t.Error("fatal error was not delivered")
+	}
+
+	res, err = b.AddJob(Job[string]{Id: 2, Data: "foobar"})
+	if err != nil {
+		t.Error("batcher shut down without WithShutdownOnFatal")
+	}
+
+	if str, err := res.Get(); err != nil || str != "FOOBAR" {
+		t.Error("failed to process job 2")
+	}
+
+	if _, err := b.FatalJob(); err != nil {
+		t.Error("fatal job reported without a fatal shutdown")
+	}
+}
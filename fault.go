@@ -0,0 +1,27 @@
+package microbatcher
+
+import "math/rand/v2"
+
+// injectFaults runs the fault injector against a random fraction of the
+// data, returning the first error it injects. It does nothing unless a
+// fault injector was configured, which is only possible in builds with the
+// chaos tag.
+func (b *Batcher[A, B]) injectFaults(data ...A) error {
+	if b.faultInjector == nil {
+		return nil
+	}
+
+	for _, d := range data {
+		if rand.Float64() >= b.faultFraction {
+			continue
+		}
+
+		b.stats.faultsInjected.Add(1)
+
+		if err := b.faultInjector(d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build chaos
+
+package microbatcher
+
+// WithFaultInjector runs the injector against a random fraction of jobs, as
+// part of processing them, so that a pipeline's handling of failures can be
+// chaos tested without modifying the real processor. The injector can
+// sleep to inject latency, and any error it returns is treated as the
+// processor's error for the job, or for its whole batch with batch and
+// streaming processors, in which case the processor is not called. The
+// injector is run on each attempt of a retried job. The number of jobs the
+// injector was run against is reported by Stats as FaultsInjected.
+//
+// This option is only available in builds with the chaos tag, such as
+// go test -tags chaos, so it can never be enabled in a production build.
+func WithFaultInjector[A any, B any](fraction float64, injector func(A) error) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.faultFraction = fraction
+		b.faultInjector = injector
+	}
+}
@@ -0,0 +1,82 @@
+//go:build chaos
+
+package microbatcher
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatcherFaultInjectorFailsJobs(t *testing.T) {
+	injector := func(string) error {
+		return errTransient
+	}
+
+	b := NewBatcher(strings.ToUpper, FIVE_MINUTES, 1, WithFaultInjector[string, string](1, injector))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if _, err := res.Get(); !errors.Is(err, errTransient) {
+		t.Error("fault was not injected")
+	}
+
+	if b.Stats().FaultsInjected != 1 {
+		t.Error("injected fault was not counted")
+	}
+}
+
+func TestBatcherFaultInjectorFraction(t *testing.T) {
+	injector := func(string) error {
+		t.Error("fault injected outside of the fraction")
+		return errTransient
+	}
+
+	b := NewBatcher(strings.ToUpper, FIVE_MINUTES, 1, WithFaultInjector[string, string](0, injector))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if str, err := res.Get(); err != nil || str != "FOOBAR" {
+		t.Error("failed to process job 1")
+	}
+
+	if b.Stats().FaultsInjected != 0 {
+		t.Error("fault was counted without being injected")
+	}
+}
+
+func TestBulkBatcherFaultInjectorDelaysBatch(t *testing.T) {
+	injector := func(string) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+
+	b := NewBulkBatcher(uppercaseStrings, FIVE_MINUTES, 2, WithFaultInjector[string, string](1, injector))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	for i, res := range addJobs(t, b, "hello world", "foobar") {
+		result := res.GetResult()
+		if result.Err != nil || result.Duration < 20*time.Millisecond {
+			t.Errorf("latency was not injected into job %d", i)
+		}
+	}
+
+	if b.Stats().FaultsInjected != 2 {
+		t.Error("injected latency was not counted")
+	}
+}
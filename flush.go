@@ -0,0 +1,80 @@
+package microbatcher
+
+import "time"
+
+// The number of flush notifications buffered for a slow listener before
+// further notifications are dropped.
+const defaultFlushedBuffer = 64
+
+// FlushReason describes what triggered a batch to be flushed.
+type FlushReason int
+
+const (
+	// FlushSize indicates the queue reached the batch size.
+	FlushSize FlushReason = iota
+	// FlushTimer indicates the ticker fired.
+	FlushTimer
+	// FlushMemory indicates the queue reached its memory budget.
+	FlushMemory
+	// FlushShutdown indicates the Batcher was shutting down.
+	FlushShutdown
+	// FlushManual indicates Flush was called.
+	FlushManual
+	// FlushIdle indicates no jobs were added within the idle timeout.
+	FlushIdle
+	// FlushFirst indicates the first job after an idle gap was added.
+	FlushFirst
+)
+
+func (r FlushReason) String() string {
+	switch r {
+	case FlushSize:
+		return "size"
+	case FlushTimer:
+		return "timer"
+	case FlushMemory:
+		return "memory"
+	case FlushShutdown:
+		return "shutdown"
+	case FlushManual:
+		return "manual"
+	case FlushIdle:
+		return "idle"
+	case FlushFirst:
+		return "first"
+	default:
+		return "unknown"
+	}
+}
+
+// FlushInfo describes a batch of jobs that has been flushed and processed.
+type FlushInfo struct {
+	// Id of the batch, incremented for each batch flushed by the Batcher.
+	BatchId uint64
+	// Number of jobs in the batch.
+	Size int
+	// Ids of the jobs in the batch, in the order they were flushed.
+	JobIds []int
+	// What triggered the batch to be flushed.
+	Reason FlushReason
+	// Time taken for every job in the batch to be processed.
+	Duration time.Duration
+}
+
+// Flushed returns a channel that receives a FlushInfo after each batch has
+// been processed, allowing consumers to act only once a batch has gone out.
+// Notifications are buffered, see WithFlushedBuffer, and are dropped rather
+// than stalling processing when the buffer is full. The channel is closed
+// once the Batcher has shut down.
+func (b *Batcher[A, B]) Flushed() <-chan FlushInfo {
+	return b.flushed
+}
+
+// notifyFlushed emits the flush notification without blocking, dropping it
+// if the buffer of the flushed channel is full.
+func (b *Batcher[A, B]) notifyFlushed(info FlushInfo) {
+	select {
+	case b.flushed <- info:
+	default:
+	}
+}
@@ -0,0 +1,141 @@
+package microbatcher
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatcherFlushedNotifiesAfterProcessing(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 2)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	for i := 1; i <= 2; i++ {
+		_, err := b.AddJob(Job[string]{Id: i, Data: "foobar"})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	select {
+	case info := <-b.Flushed():
+		if info.BatchId != 1 || info.Size != 2 || info.Reason != FlushSize {
+			t.Error("flush notification has incorrect details")
+		}
+	case <-time.After(time.Second):
+		t.Error("no flush notification was received")
+	}
+}
+
+func TestBatcherFlushedDropsWhenFull(t *testing.T) {
+	b := NewBatcher(uppercaseString, ONE_MILLISECOND, 1, WithFlushedBuffer[string, string](1))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	for i := 1; i <= 3; i++ {
+		res, err := b.AddJob(Job[string]{Id: i, Data: "foobar"})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+
+		if _, err := res.Get(); err != nil {
+			t.Errorf("failed to process job %d", i)
+		}
+	}
+
+	// Allow time for notifications to be emitted.
+	time.Sleep(10 * time.Millisecond)
+
+	if len(b.Flushed()) != 1 {
+		t.Error("flush notifications were not dropped when the buffer was full")
+	}
+}
+
+func TestFlushReasonString(t *testing.T) {
+	if FlushTimer.String() != "timer" {
+		t.Error("incorrect flush reason string")
+	}
+}
+
+func TestBatcherFlushAndWait(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	if err := b.FlushAndWait(context.Background()); err != nil {
+		t.Error("flushing an empty queue returned an error")
+	}
+
+	results := addJobs(t, b, "hello world", "foobar")
+
+	if err := b.FlushAndWait(context.Background()); err != nil {
+		t.Error("flush returned an error for successful jobs")
+	}
+
+	// Every job has completed by the time FlushAndWait returns.
+	if b.Stats().Completed != 2 {
+		t.Error("flush returned before its jobs completed")
+	}
+
+	for i, res := range results {
+		if str, err := res.Get(); err != nil || str == "" {
+			t.Errorf("failed to process job %d correctly", i)
+		}
+	}
+}
+
+func TestBatcherFlushAndWaitJoinsErrors(t *testing.T) {
+	errBad := errors.New("bad input")
+	processor := func(in string) (string, error) {
+		if strings.HasPrefix(in, "bad") {
+			return "", errBad
+		}
+
+		return strings.ToUpper(in), nil
+	}
+
+	b := NewFallibleBatcher(processor, FIVE_MINUTES, 10)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	addJobs(t, b, "bad one", "foobar", "bad two")
+
+	err := b.FlushAndWait(context.Background())
+	if !errors.Is(err, errBad) {
+		t.Fatal("flush did not return the job errors")
+	}
+
+	if msg := err.Error(); !strings.Contains(msg, "job 0: bad input") || !strings.Contains(msg, "job 2: bad input") || strings.Contains(msg, "job 1") {
+		t.Errorf("flush did not join the error of each failed job, got %q", msg)
+	}
+}
+
+func TestBatcherFlushAndWaitRespectsContext(t *testing.T) {
+	release := make(chan struct{})
+	processor := func(in string) string {
+		<-release
+		return strings.ToUpper(in)
+	}
+
+	b := NewBatcher(processor, FIVE_MINUTES, 10)
+
+	go b.Start()
+	defer b.Shutdown()
+	defer close(release)
+
+	addJobs(t, b, "foobar")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := b.FlushAndWait(ctx); err != context.DeadlineExceeded {
+		t.Error("wait did not return the context error")
+	}
+}
@@ -0,0 +1,94 @@
+package microbatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// handler serves HTTP requests by submitting each as a job to a Batcher.
+type handler[A any, B any] struct {
+	batcher *Batcher[A, B]
+	// Function returning the id of the job for a request.
+	id func(*http.Request) (int, error)
+}
+
+// NewHandler returns an http.Handler that exposes the Batcher as a per
+// request API, while the requests are processed in batches. The JSON body
+// of each POST request is decoded into the data of a job, which is added
+// with the request's context, and the job's result is encoded as the JSON
+// body of the response.
+//
+// Each job is given the id returned by id for its request, such as one
+// parsed from a header or drawn from a counter shared with the Batcher's
+// other producers, so that ids are unique across every source of jobs. If
+// id returns an error, the request is rejected without adding a job.
+//
+// If the request is cancelled, the handler stops waiting for the result,
+// and the job is left out of its batch if it has not yet been processed.
+// Errors are reported with a plain text body and the status code:
+//   - 400 Bad Request if the body cannot be decoded or id returns an error
+//   - 405 Method Not Allowed if the request is not a POST
+//   - 429 Too Many Requests if the queue is full or at its memory limit
+//   - 503 Service Unavailable if the Batcher is shutting down, sealed or
+//     not yet started
+//   - 504 Gateway Timeout if the request's context is done or the job
+//     timed out waiting on the queue
+//   - 500 Internal Server Error for an error returned by the processor
+func NewHandler[A any, B any](b *Batcher[A, B], id func(*http.Request) (int, error)) http.Handler {
+	return &handler[A, B]{batcher: b, id: id}
+}
+
+func (h *handler[A, B]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	id, err := h.id(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var data A
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	job := Job[A]{Id: id, Data: data}
+
+	res, err := h.batcher.AddJobContext(r.Context(), job)
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+
+	val, err := res.GetContext(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), statusFor(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(val)
+}
+
+// statusFor returns the HTTP status code for an error returned by the
+// Batcher or its processor.
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, ErrQueueFull), errors.Is(err, ErrMemoryLimit):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrShuttingDown), errors.Is(err, ErrSealed), errors.Is(err, ErrNotStarted):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrQueueTimeout):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
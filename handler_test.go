@@ -0,0 +1,133 @@
+package microbatcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func serve(h http.Handler, method string, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, "/", strings.NewReader(body)))
+
+	return rec
+}
+
+// fixedId returns the same job id for every request.
+func fixedId(*http.Request) (int, error) {
+	return 1, nil
+}
+
+// headerId returns the job id given in the X-Job-Id header of the request.
+func headerId(r *http.Request) (int, error) {
+	return strconv.Atoi(r.Header.Get("X-Job-Id"))
+}
+
+func TestHandlerProcessesRequests(t *testing.T) {
+	b := NewBatcher(strings.ToUpper, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	rec := serve(NewHandler(b, fixedId), http.MethodPost, `"foobar"`)
+
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `"FOOBAR"` {
+		t.Errorf("request was not processed correctly: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec.Header().Get("Content-Type") != "application/json" {
+		t.Error("response was not encoded as JSON")
+	}
+}
+
+func TestHandlerRejectsInvalidRequests(t *testing.T) {
+	b := NewBatcher(strings.ToUpper, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	h := NewHandler(b, fixedId)
+
+	if rec := serve(h, http.MethodPost, "foobar"); rec.Code != http.StatusBadRequest {
+		t.Error("invalid body was not rejected")
+	}
+
+	if rec := serve(h, http.MethodGet, ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Error("non POST request was not rejected")
+	}
+}
+
+func TestHandlerMapsErrors(t *testing.T) {
+	processor := func(in string) (string, error) {
+		return "", errTransient
+	}
+
+	b := NewFallibleBatcher(processor, FIVE_MINUTES, 1)
+
+	go b.Start()
+
+	h := NewHandler(b, fixedId)
+
+	if rec := serve(h, http.MethodPost, `"foobar"`); rec.Code != http.StatusInternalServerError {
+		t.Error("processor error was not reported as an internal server error")
+	}
+
+	b.Shutdown()
+
+	if rec := serve(h, http.MethodPost, `"foobar"`); rec.Code != http.StatusServiceUnavailable {
+		t.Error("shut down batcher was not reported as unavailable")
+	}
+}
+
+func TestHandlerStopsWaitingOnCancellation(t *testing.T) {
+	b := NewBatcher(strings.ToUpper, FIVE_MINUTES, 10)
+	defer b.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`"foobar"`)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewHandler(b, fixedId).ServeHTTP(rec, req)
+	}()
+
+	cancel()
+	<-done
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Error("cancelled request was not abandoned")
+	}
+}
+
+func TestHandlerUsesRequestId(t *testing.T) {
+	b := NewBatcher(strings.ToUpper, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	h := NewHandler(b, headerId)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`"foobar"`))
+	req.Header.Set("X-Job-Id", "42")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("request was not processed: %d %s", rec.Code, rec.Body)
+	}
+
+	if info := <-b.Flushed(); !slices.Equal(info.JobIds, []int{42}) {
+		t.Errorf("job was not given the id from the request: %v", info.JobIds)
+	}
+
+	if rec := serve(h, http.MethodPost, `"foobar"`); rec.Code != http.StatusBadRequest {
+		t.Error("request without an id was not rejected")
+	}
+}
@@ -0,0 +1,172 @@
+package microbatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The number of most recently completed jobs the error rate reported by
+// Health is calculated over.
+const healthWindow = 100
+
+// HealthStatus is an overall verdict on whether a Batcher is keeping up.
+type HealthStatus int
+
+const (
+	// Healthy indicates the Batcher is keeping up.
+	Healthy HealthStatus = iota
+	// Degraded indicates the Batcher is falling behind or seeing errors,
+	// but is still making progress.
+	Degraded
+	// Unhealthy indicates the Batcher is not keeping up, or has shut down.
+	Unhealthy
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case Healthy:
+		return "healthy"
+	case Degraded:
+		return "degraded"
+	case Unhealthy:
+		return "unhealthy"
+	default:
+		return "unknown"
+	}
+}
+
+// HealthThresholds are the limits at which Health reports a Batcher as
+// degraded or unhealthy. A threshold of zero disables that check.
+type HealthThresholds struct {
+	// Age of the oldest job on the queue.
+	DegradedAge  time.Duration
+	UnhealthyAge time.Duration
+	// Fraction of the most recently completed jobs that failed, between 0
+	// and 1.
+	DegradedErrorRate  float64
+	UnhealthyErrorRate float64
+	// Fraction of the maximum queue size that is filled, between 0 and 1.
+	// Ignored if the queue is unbounded.
+	DegradedQueueFill  float64
+	UnhealthyQueueFill float64
+}
+
+// defaultHealthThresholds returns the thresholds used unless overridden by
+// WithHealthThresholds, with ages relative to the flush frequency.
+func defaultHealthThresholds(frequency time.Duration) HealthThresholds {
+	return HealthThresholds{
+		DegradedAge:        2 * frequency,
+		UnhealthyAge:       10 * frequency,
+		DegradedErrorRate:  0.1,
+		UnhealthyErrorRate: 0.5,
+		DegradedQueueFill:  0.8,
+		UnhealthyQueueFill: 1,
+	}
+}
+
+// errorWindow records whether each of the most recently completed jobs
+// failed.
+type errorWindow struct {
+	mu     sync.Mutex
+	failed [healthWindow]bool
+	next   int
+	count  int
+}
+
+// record adds the outcome of a completed job to the window, replacing the
+// oldest outcome once the window is full.
+func (w *errorWindow) record(failed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.failed[w.next] = failed
+	w.next = (w.next + 1) % healthWindow
+	w.count = min(w.count+1, healthWindow)
+}
+
+// rate returns the fraction of the recorded outcomes that failed, or zero
+// if none have been recorded.
+func (w *errorWindow) rate() float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.count == 0 {
+		return 0
+	}
+
+	failed := 0
+	for _, f := range w.failed[:w.count] {
+		if f {
+			failed++
+		}
+	}
+
+	return float64(failed) / float64(w.count)
+}
+
+// isFailure reports whether a job completed with the error failed, rather
+// than succeeding or being abandoned because its context was done.
+func isFailure(err error) bool {
+	return err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// Health returns a verdict on whether the Batcher is keeping up, suitable
+// for reporting from a health check endpoint. It combines the age of the
+// oldest job on the queue, the error rate of the most recently completed
+// jobs and how full the queue is, against the thresholds configured by
+// WithHealthThresholds, reporting the worst status any of them reach. A
+// Batcher that has shut down is always Unhealthy.
+func (b *Batcher[A, B]) Health() HealthStatus {
+	b.lock()
+	defer b.unlock()
+
+	return b.health(time.Now())
+}
+
+// health returns the health of the Batcher. The mutex must be held.
+func (b *Batcher[A, B]) health(now time.Time) HealthStatus {
+	if b.shuttingDown {
+		return Unhealthy
+	}
+
+	t := b.healthThresholds
+	status := Healthy
+
+	check := func(value, degraded, unhealthy float64) {
+		if unhealthy > 0 && value >= unhealthy {
+			status = Unhealthy
+		} else if degraded > 0 && value >= degraded {
+			status = max(status, Degraded)
+		}
+	}
+
+	check(float64(b.oldestPendingAge(now)), float64(t.DegradedAge), float64(t.UnhealthyAge))
+	check(b.recentErrors.rate(), t.DegradedErrorRate, t.UnhealthyErrorRate)
+
+	if b.maxQueueSize > 0 {
+		check(float64(len(b.jobs))/float64(b.maxQueueSize), t.DegradedQueueFill, t.UnhealthyQueueFill)
+	}
+
+	return status
+}
+
+// String describes the current state of the Batcher, such as for logging.
+func (b *Batcher[A, B]) String() string {
+	b.lock()
+	defer b.unlock()
+
+	now := time.Now()
+
+	queued := fmt.Sprint(len(b.jobs))
+	if b.maxQueueSize > 0 {
+		queued += fmt.Sprintf("/%d", b.maxQueueSize)
+	}
+
+	return fmt.Sprintf(
+		"Batcher{batch_size: %d, frequency: %s, queued: %s, in_flight: %d, oldest_pending: %s, health: %s}",
+		b.batchSize, b.frequency, queued, b.stats.inFlight.Load(), b.oldestPendingAge(now), b.health(now),
+	)
+}
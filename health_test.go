@@ -0,0 +1,98 @@
+package microbatcher
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatcherHealthQueueFill(t *testing.T) {
+	b := NewBatcher(strings.ToUpper, FIVE_MINUTES, 100, WithMaxQueueSize[string, string](10))
+	defer b.Shutdown()
+
+	if b.Health() != Healthy {
+		t.Error("empty batcher was not healthy")
+	}
+
+	for i := range 8 {
+		if _, err := b.AddJob(Job[string]{Id: i, Data: "foobar"}); err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	if b.Health() != Degraded {
+		t.Error("nearly full batcher was not degraded")
+	}
+
+	for i := 8; i < 10; i++ {
+		if _, err := b.AddJob(Job[string]{Id: i, Data: "foobar"}); err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	if b.Health() != Unhealthy {
+		t.Error("full batcher was not unhealthy")
+	}
+}
+
+func TestBatcherHealthOldestPendingAge(t *testing.T) {
+	b := NewBatcher(strings.ToUpper, FIVE_MINUTES, 100, WithHealthThresholds[string, string](HealthThresholds{
+		DegradedAge: 10 * time.Millisecond,
+	}))
+	defer b.Shutdown()
+
+	if _, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"}); err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if b.Health() != Degraded {
+		t.Error("batcher with a stale job was not degraded")
+	}
+}
+
+func TestBatcherHealthErrorRate(t *testing.T) {
+	processor := func(in string) (string, error) {
+		return "", errTransient
+	}
+
+	b := NewFallibleBatcher(processor, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	res.Get()
+
+	if b.Health() != Unhealthy {
+		t.Error("failing batcher was not unhealthy")
+	}
+}
+
+func TestBatcherHealthAfterShutdown(t *testing.T) {
+	b := NewBatcher(strings.ToUpper, FIVE_MINUTES, 1)
+	b.Shutdown()
+
+	if b.Health() != Unhealthy {
+		t.Error("shut down batcher was not unhealthy")
+	}
+}
+
+func TestBatcherString(t *testing.T) {
+	b := NewBatcher(strings.ToUpper, FIVE_MINUTES, 100, WithMaxQueueSize[string, string](10))
+	defer b.Shutdown()
+
+	if _, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"}); err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	str := b.String()
+	if !strings.Contains(str, "batch_size: 100") || !strings.Contains(str, "queued: 1/10") || !strings.Contains(str, "health: healthy") {
+		t.Errorf("batcher was not described correctly: %s", str)
+	}
+}
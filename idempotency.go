@@ -0,0 +1,31 @@
+package microbatcher
+
+import "context"
+
+// batchKeyContextKey is the context key for the idempotency key of a batch.
+type batchKeyContextKey struct{}
+
+// BatchIdempotencyKey returns the idempotency key of the batch being
+// processed from the context passed to a batch processor, as derived by the
+// function given to WithBatchIdempotencyKey. The key is the same for every
+// retry of a batch, so can be sent to an idempotent downstream to discard
+// repeated requests. It reports false if no key was derived.
+func BatchIdempotencyKey(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(batchKeyContextKey{}).(string)
+	return key, ok
+}
+
+// withBatchKey returns the context carrying the idempotency key of the
+// batch, if keys are configured.
+func (b *Batcher[A, B]) withBatchKey(ctx context.Context, batch []*batchJob[A, B]) context.Context {
+	if b.batchKey == nil {
+		return ctx
+	}
+
+	jobs := make([]Job[A], len(batch))
+	for i, job := range batch {
+		jobs[i] = *job.job
+	}
+
+	return context.WithValue(ctx, batchKeyContextKey{}, b.batchKey(jobs))
+}
@@ -0,0 +1,102 @@
+package microbatcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// jobIdsKey derives a batch key from the ids of its jobs.
+func jobIdsKey(jobs []Job[string]) string {
+	ids := make([]int, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.Id
+	}
+
+	return fmt.Sprint(ids)
+}
+
+func TestBulkBatcherIdempotencyKeyReusedAcrossRetries(t *testing.T) {
+	var mu sync.Mutex
+	keys := []string{}
+
+	processor := func(ctx context.Context, in []string) ([]string, error) {
+		key, ok := BatchIdempotencyKey(ctx)
+		if !ok {
+			t.Error("batch processor was not given an idempotency key")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		keys = append(keys, key)
+
+		// Fail the first attempt of each batch.
+		if len(keys)%2 == 1 {
+			return nil, errTransient
+		}
+
+		return uppercaseStrings(ctx, in)
+	}
+
+	b := NewBulkBatcher(processor, FIVE_MINUTES, 2,
+		WithRetry[string, string](2, ONE_MILLISECOND),
+		WithBatchIdempotencyKey[string, string](jobIdsKey),
+	)
+
+	go b.Start()
+
+	for _, res := range addJobs(t, b, "hello world", "foobar") {
+		if _, err := res.Get(); err != nil {
+			t.Error("failed to process the retried batch")
+		}
+	}
+
+	results := []*JobResult[string]{}
+	for _, job := range []Job[string]{{Id: 2, Data: "baz"}, {Id: 3, Data: "qux"}} {
+		res, err := b.AddJob(job)
+		if err != nil {
+			t.Fatalf("failed to add job %d", job.Id)
+		}
+
+		results = append(results, res)
+	}
+
+	for _, res := range results {
+		if _, err := res.Get(); err != nil {
+			t.Error("failed to process the retried batch")
+		}
+	}
+
+	b.Shutdown()
+
+	if len(keys) != 4 || keys[0] != keys[1] || keys[2] != keys[3] {
+		t.Errorf("key was not reused across retries, got %v", keys)
+	}
+
+	if keys[0] == keys[2] {
+		t.Error("distinct batches were given the same key")
+	}
+}
+
+func TestBulkBatcherWithoutIdempotencyKey(t *testing.T) {
+	processor := func(ctx context.Context, in []string) ([]string, error) {
+		if _, ok := BatchIdempotencyKey(ctx); ok {
+			t.Error("batch processor was given an idempotency key")
+		}
+
+		return uppercaseStrings(ctx, in)
+	}
+
+	b := NewBulkBatcher(processor, FIVE_MINUTES, 1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	for _, res := range addJobs(t, b, "foobar") {
+		if _, err := res.Get(); err != nil {
+			t.Error("failed to process job")
+		}
+	}
+}
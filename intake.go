@@ -0,0 +1,40 @@
+package microbatcher
+
+import "sync"
+
+// ticketLock is a mutex that is acquired in strict arrival order. Each
+// caller takes the next ticket and waits until its ticket is served, so no
+// caller can overtake another that arrived before it.
+type ticketLock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	next    uint64
+	serving uint64
+}
+
+func newTicketLock() *ticketLock {
+	l := &ticketLock{}
+	l.cond = sync.NewCond(&l.mu)
+
+	return l
+}
+
+func (l *ticketLock) lock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ticket := l.next
+	l.next++
+
+	for l.serving != ticket {
+		l.cond.Wait()
+	}
+}
+
+func (l *ticketLock) unlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.serving++
+	l.cond.Broadcast()
+}
@@ -0,0 +1,105 @@
+package microbatcher
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTicketLockServesInArrivalOrder(t *testing.T) {
+	l := newTicketLock()
+	l.lock()
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			l.lock()
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			l.unlock()
+		}()
+
+		// Wait for the goroutine to take its ticket before starting the
+		// next one, so that arrival order is known.
+		for {
+			l.mu.Lock()
+			arrived := l.next == uint64(i+2)
+			l.mu.Unlock()
+
+			if arrived {
+				break
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	l.unlock()
+	wg.Wait()
+
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("lock was not served in arrival order: %v", order)
+		}
+	}
+}
+
+func TestBatcherFairIntakeWithManyProducers(t *testing.T) {
+	const producers, perProducer = 32, 50
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, producers*perProducer+1, WithFairIntake[string, string]())
+
+	go b.Start()
+	defer b.Shutdown()
+
+	var wg sync.WaitGroup
+
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < perProducer; i++ {
+				if _, err := b.AddJob(Job[string]{Id: p*perProducer + i, Data: "foobar"}); err != nil {
+					t.Errorf("failed to add job %d for producer %d", i, p)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	b.Flush()
+
+	info := <-b.Flushed()
+	if info.Size != producers*perProducer {
+		t.Fatal("not every producer's jobs were queued")
+	}
+
+	// Each producer's jobs must be queued in the order it submitted them,
+	// and every producer must be represented.
+	next := make([]int, producers)
+
+	for _, id := range info.JobIds {
+		p, i := id/perProducer, id%perProducer
+		if i != next[p] {
+			t.Fatalf("jobs of producer %d were queued out of order", p)
+		}
+
+		next[p]++
+	}
+
+	for p, n := range next {
+		if n != perProducer {
+			t.Errorf("producer %d had %d of %d jobs queued", p, n, perProducer)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package microbatcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verifyNoLeaks fails the test if goroutines started during the test are
+// still running once the test completes.
+func verifyNoLeaks(t *testing.T) {
+	t.Helper()
+
+	before := len(goroutines())
+
+	t.Cleanup(func() {
+		deadline := time.Now().Add(time.Second)
+
+		for {
+			running := goroutines()
+			if len(running) <= before {
+				return
+			}
+
+			if time.Now().After(deadline) {
+				t.Errorf("leaked %d goroutines:\n\n%s", len(running)-before, strings.Join(running, "\n\n"))
+				return
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+// goroutines returns the stacks of the running goroutines, other than the
+// caller and the signal handling goroutine that os/signal starts once and
+// never stops.
+func goroutines() []string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+
+	var stacks []string
+	for i, stack := range strings.Split(string(buf), "\n\n") {
+		if i == 0 || strings.Contains(stack, "os/signal.loop") {
+			continue
+		}
+
+		stacks = append(stacks, stack)
+	}
+
+	return stacks
+}
+
+func TestNoLeaksWhenContextCancelledBeforeFlush(t *testing.T) {
+	verifyNoLeaks(t)
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	go b.Start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	res, err := b.AddJobContext(ctx, Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	cancel()
+	b.Shutdown()
+
+	if _, err := res.Get(); !errors.Is(err, context.Canceled) {
+		t.Error("cancelled job was processed")
+	}
+}
+
+func TestNoLeaksWhenContextCancelledDuringRetries(t *testing.T) {
+	verifyNoLeaks(t)
+
+	processor := func(in string) (string, error) {
+		return "", errTransient
+	}
+
+	b := NewFallibleBatcher(processor, FIVE_MINUTES, 1, WithRetry[string, string](100, FIVE_MINUTES))
+
+	go b.Start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	res, err := b.AddJobContext(ctx, Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	// Allow time for the first attempt to fail before cancelling.
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	if _, err := res.Get(); !errors.Is(err, errTransient) {
+		t.Error("last error was not delivered after cancellation")
+	}
+
+	b.Shutdown()
+}
+
+func TestNoLeaksWhenWaitCancelled(t *testing.T) {
+	verifyNoLeaks(t)
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	go b.Start()
+
+	if _, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"}); err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := b.WaitUntilBelow(ctx, 1); err != context.DeadlineExceeded {
+		t.Error("wait did not return the context error")
+	}
+
+	b.Shutdown()
+}
+
+func TestNoLeaksAfterShutdown(t *testing.T) {
+	verifyNoLeaks(t)
+
+	b := NewBatcher(strings.ToUpper, ONE_MILLISECOND, 2,
+		WithIdleTimeout[string, string](ONE_MILLISECOND),
+		WithRetry[string, string](3, ONE_MILLISECOND),
+	)
+	FlushOnSignal(b, os.Interrupt)
+
+	go b.Start()
+
+	for i := 1; i <= 5; i++ {
+		if _, err := b.AddJob(Job[string]{Id: i, Data: "foobar"}); err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	b.Shutdown()
+}
@@ -0,0 +1,64 @@
+package microbatcher
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A program that copies a Batcher by value, which go vet should reject.
+const copyProgram = `package main
+
+import (
+	"fmt"
+	"time"
+
+	microbatcher "github.com/callum-thomas/micro-batcher"
+)
+
+func main() {
+	b := microbatcher.NewBatcher(func(in int) int { return in }, time.Second, 1)
+	copied := *b
+	fmt.Println(copied.Stats())
+}
+`
+
+func TestGoVetReportsBatcherCopies(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go vet in short mode")
+	}
+
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	root, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	gomod := "module copytest\n\ngo 1.22\n\n" +
+		"require github.com/callum-thomas/micro-batcher v0.0.0\n\n" +
+		"replace github.com/callum-thomas/micro-batcher => " + root + "\n"
+
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(copyProgram), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(gobin, "vet", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+
+	out, err := cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "copies lock") {
+		t.Errorf("go vet did not report the copied batcher: %s", out)
+	}
+}
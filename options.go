@@ -0,0 +1,441 @@
+package microbatcher
+
+import (
+	"maps"
+	"time"
+)
+
+// Option configures optional behaviour of a Batcher.
+type Option[A any, B any] func(*Batcher[A, B])
+
+// WithMemoryBudget configures the Batcher to flush the whole queue early
+// once the number of queued jobs multiplied by the estimated size of a
+// single job, in bytes, reaches the given budget. This keeps the memory
+// held by the queue in check independently of the batch size. Jobs in
+// flight are not counted towards the budget, as flushing cannot free them,
+// but the estimated size of the queued and in-flight jobs together is
+// reported by Stats as EstimatedBytes, so the memory held while a slow
+// downstream processes earlier batches can be monitored. Use
+// WithMaxMemory to reject jobs instead once the queue is too large.
+func WithMemoryBudget[A any, B any](budget int64, jobSize int64) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.memoryBudget = budget
+		b.jobSize = jobSize
+	}
+}
+
+// WithMaxQueueSize limits the number of jobs that can be queued at once.
+// Jobs submitted while the queue is full are rejected with ErrQueueFull,
+// unless an overflow batcher has been configured.
+func WithMaxQueueSize[A any, B any](size int) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.maxQueueSize = size
+	}
+}
+
+// WithOverflowBatcher routes jobs that would be rejected due to a full
+// queue to the given overflow batcher instead, which may be configured
+// differently to absorb spikes. The overflow batcher processes jobs
+// independently, delivering results to the JobResult returned by the
+// primary batcher, and must be started and shut down by the caller.
+func WithOverflowBatcher[A any, B any](overflow *Batcher[A, B]) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.overflow = overflow
+	}
+}
+
+// WithDedupeKey deduplicates queued jobs by a key computed from their data.
+// A job whose key matches a job already on the queue is not queued, and is
+// instead given the result of the queued job, so only the first job with a
+// given key is processed. The optional onDrop callback is invoked with the
+// kept and dropped jobs whenever a duplicate is collapsed, allowing callers
+// to observe drops where the keys collide but the data differs.
+func WithDedupeKey[A any, B any, K comparable](key func(A) K, onDrop func(kept Job[A], dropped Job[A])) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.dedupeKey = func(data A) any {
+			return key(data)
+		}
+		b.onDedupe = onDrop
+	}
+}
+
+// WithMaxAge configures the ticker to only flush jobs that have been queued
+// for at least maxAge, rather than the entire queue. Fresher jobs are left
+// to accumulate towards a full batch, bounding the latency of stragglers
+// without giving up size based batching.
+func WithMaxAge[A any, B any](maxAge time.Duration) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.maxAge = maxAge
+	}
+}
+
+// WithFlushedBuffer sets the number of notifications buffered on the
+// Flushed channel before further notifications are dropped. A size of zero
+// only delivers notifications to a listener that is already waiting.
+func WithFlushedBuffer[A any, B any](size int) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.flushedBuffer = size
+	}
+}
+
+// WithQueueCapacity pre-sizes the queue to hold the given number of jobs,
+// both initially and whenever the queue is emptied by a flush, avoiding the
+// cost of growing the queue on the hot path. Sizing it to at least the batch
+// size is generally a good starting point.
+func WithQueueCapacity[A any, B any](capacity int) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.queueCapacity = capacity
+	}
+}
+
+// WithResultHandler registers a handler that is invoked exactly once for
+// every completed job with the original job and its result, centralising
+// result handling for callers that do not want to track each JobResult.
+// The error is the one delivered to the JobResult, if any. The handler
+// is called from processing goroutines, so must be safe for concurrent use.
+func WithResultHandler[A any, B any](handler func(Job[A], B, error)) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.resultHandler = handler
+	}
+}
+
+// WithRetry attempts a job up to maxAttempts times, waiting backoff between
+// attempts, before delivering the processor's error. Errors wrapped with
+// Permanent, and errors wrapping ErrFatal, are delivered immediately without
+// being retried.
+func WithRetry[A any, B any](maxAttempts int, backoff time.Duration) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.maxAttempts = maxAttempts
+		b.retryBackoff = backoff
+	}
+}
+
+// WithResultCacheLRU caches the results of successfully processed jobs by
+// a key computed from their data, completing later jobs with the same key
+// immediately instead of processing them. At most maxEntries results are
+// cached, with the least recently used evicted first. Cache hits and misses
+// are reported by Stats.
+func WithResultCacheLRU[A any, B any, K comparable](key func(A) K, maxEntries int) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.cacheKey = func(data A) any {
+			return key(data)
+		}
+		b.cache = newLRUCache[B](maxEntries)
+	}
+}
+
+// WithDryRun forms and flushes batches exactly as normal, including firing
+// hooks and flush notifications, but never calls the processor. Jobs are
+// instead given the result of the stub, or the zero value if it is nil. The
+// composition of each would-be batch is reported by the Flushed channel.
+// This allows batching configuration to be validated against real traffic
+// without side effects downstream.
+func WithDryRun[A any, B any](stub func(A) B) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.dryRun = true
+		b.dryRunStub = stub
+	}
+}
+
+// WithLockMetrics measures the time spent waiting for and holding the
+// Batcher's mutex, reported by Stats, to help diagnose lock contention.
+// This adds a small overhead to every acquisition, so is disabled by
+// default. Time spent blocked in WaitUntilBelow is not included.
+func WithLockMetrics[A any, B any]() Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.lockMetrics = true
+	}
+}
+
+// WithIdleTimeout flushes the queue once no new job has been added for the
+// given duration, even if the batch is not full. Unlike the frequency, which
+// flushes on a fixed cadence, the idle timeout restarts on every new job, so
+// a burst of jobs is flushed shortly after the burst ends. Both can be used
+// together, with the queue flushed by whichever fires first.
+func WithIdleTimeout[A any, B any](timeout time.Duration) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.idleTimeout = timeout
+	}
+}
+
+// WithTimerCoalesce skips a tick of the ticker that fires within the given
+// window after a size flush. Under steady load this avoids following a full
+// batch with a tiny batch of the jobs that arrived while it was flushed,
+// reducing the number of downstream calls. The skipped jobs are flushed by
+// the next size flush or tick. A window that is not positive defaults to a
+// tenth of the frequency.
+func WithTimerCoalesce[A any, B any](window time.Duration) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		if window <= 0 {
+			window = b.frequency / 10
+		}
+
+		b.timerCoalesce = window
+	}
+}
+
+// WithFairIntake admits concurrently submitted jobs to the queue strictly in
+// the order their producers arrived. By default, jobs are queued in the order
+// producers acquire the Batcher's mutex, which is close to arrival order but
+// may let a producer overtake others under heavy contention, although the
+// mutex ensures none is starved indefinitely. Fair intake hands out tickets
+// on arrival instead, at the cost of some throughput when contended.
+func WithFairIntake[A any, B any]() Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.intake = newTicketLock()
+	}
+}
+
+// WithRequireStart rejects jobs and flushes with ErrNotStarted until the
+// Batcher has been started, rather than queuing jobs that will never be
+// processed if Start is not called. As running Start as a goroutine may not
+// mark the Batcher as started before the next job is added, StartAsync
+// should be used to start it.
+func WithRequireStart[A any, B any]() Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.requireStart = true
+	}
+}
+
+// WithErrorCooldown pauses flushing for the given duration after a batch
+// fails, so that a struggling downstream is not hammered with further
+// batches. Jobs continue to be queued during the cooldown, subject to the
+// maximum queue size, and are flushed once it ends. A manual Flush is still
+// processed during the cooldown, acting as a probe: if its batch succeeds,
+// normal flushing resumes immediately. The queue is always flushed on
+// shutdown.
+func WithErrorCooldown[A any, B any](cooldown time.Duration) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.errorCooldown = cooldown
+	}
+}
+
+// WithWeightedPriority composes batches across the priority classes of the
+// queued jobs in proportion to the given weights, keyed by Job.Priority.
+// Higher weighted classes are given more of each batch, while lower
+// weighted classes with queued jobs still receive a share, so they are not
+// starved as they would be by strict priority. For example, weights of 3
+// and 1 give the first class three jobs for every one of the second while
+// both have jobs queued. Jobs are taken from each class in the order they
+// were queued, and classes without a positive weight have a weight of one.
+//
+// Weights only apply when a batch is drawn from a queue holding more jobs
+// than the batch size; flushes of the entire queue are unaffected.
+func WithWeightedPriority[A any, B any](weights map[int]int) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.weights = maps.Clone(weights)
+		b.credits = map[int]int{}
+	}
+}
+
+// WithWAL records each accepted job in the write-ahead log before it is
+// queued, and marks it done once its result has been delivered, for
+// at-least-once processing across restarts. Jobs served from the result
+// cache are never queued, so are not recorded.
+func WithWAL[A any, B any](wal WALWriter[A]) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.wal = wal
+	}
+}
+
+// WithExecutor hands the work of processing each batch to the executor as
+// tasks, rather than running each in a new goroutine, so that processing
+// can be run on an existing goroutine pool, bounded by a semaphore or bound
+// to a locked OS thread. With a per job processor, there is one task per
+// job, otherwise one task per batch.
+//
+// The executor is called with the Batcher's mutex held, so it should hand
+// the task off rather than run it inline. It may block to apply
+// backpressure, although this blocks new jobs from being queued meanwhile.
+// The Batcher still uses its own goroutines for bookkeeping, such as
+// reporting flushes, which do not run the processor.
+func WithExecutor[A any, B any](executor func(task func())) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.executor = executor
+	}
+}
+
+// WithMaxQueueWait evicts jobs that wait on the queue for longer than the
+// given duration without being flushed, completing them with
+// ErrQueueTimeout. This bounds the latency of each job when the Batcher
+// cannot keep up, and is separate from the time taken to process a job
+// once it has been flushed.
+func WithMaxQueueWait[A any, B any](wait time.Duration) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.maxQueueWait = wait
+	}
+}
+
+// WithConcurrency limits the number of processing tasks run at once to n,
+// running them on a pool of n workers rather than a goroutine each. With a
+// per job processor, each task processes one job, otherwise a whole batch.
+// The limit can be changed while running with SetConcurrency. It has no
+// effect when WithExecutor is used, and is ignored if n is not positive.
+func WithConcurrency[A any, B any](n int) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		if n > 0 {
+			b.pool = newWorkerPool(n)
+		}
+	}
+}
+
+// WithActivityCallback calls the callback with true when the queue goes
+// from empty to holding jobs, and with false when it becomes empty again,
+// so that external systems such as autoscalers can react to the Batcher
+// becoming busy or idle. Calls are made in order, at most once per minimum
+// interval, with rapid changes in between collapsed into the latest state,
+// and a change that is reversed within the interval not reported at all.
+// The callback is called from its own goroutine and must not block for
+// long. The queue becoming inactive on shutdown is reported before Shutdown
+// returns.
+func WithActivityCallback[A any, B any](callback func(active bool), minInterval time.Duration) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.onActivity = callback
+		b.activityInterval = minInterval
+	}
+}
+
+// WithBatchIdempotencyKey derives an idempotency key for each batch from its
+// jobs, such as from their ids or a hash of their data, which is available
+// to the batch processor through BatchIdempotencyKey on its context. The key
+// is derived once per batch, so it is reused when the batch is retried,
+// letting an idempotent downstream discard the repeated request. The
+// function should return distinct keys for distinct batches. Keys are only
+// derived for batch and streaming processors.
+func WithBatchIdempotencyKey[A any, B any](key func(jobs []Job[A]) string) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.batchKey = key
+	}
+}
+
+// WithSampledLogging logs the lifecycle of a random sample of jobs, as they
+// are queued, flushed and completed, to the default slog logger. The
+// fraction of jobs sampled is between 0 and 1, and is decided once per job,
+// so each sampled job is logged at every stage while log volume stays
+// manageable for busy Batchers. The counters reported by Stats always cover
+// every job.
+func WithSampledLogging[A any, B any](fraction float64) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.logFraction = fraction
+	}
+}
+
+// WithCompactor merges the data of each new job into a queued job when the
+// data can be combined, such as counter increments for the same name, so
+// that aggregatable workloads are processed in fewer, smaller batches. The
+// compactor is given the data of a queued job and of the new job, and
+// returns the merged data and true if they can be combined. Queued jobs are
+// tried from the most recently added.
+//
+// A merged job is not queued separately. The queued job keeps its id and
+// takes the merged data, and the result of processing it is delivered to
+// both jobs, with each JobResult keeping the id of its own job. Only the
+// queued job is reported by the Flushed channel.
+func WithCompactor[A any, B any](compactor func(existing, incoming A) (A, bool)) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.compactor = compactor
+	}
+}
+
+// WithMaxMemory limits the estimated size of the jobs on the queue to the
+// given number of bytes, with the size of each job's data estimated by
+// sizeOf. A job that would take the queue over the limit is rejected with
+// ErrMemoryLimit, as is any job larger than the limit itself, providing a
+// hard guardrail against unbounded queue growth for variable sized data.
+// Jobs no longer count towards the limit once they are flushed. Jobs merged
+// by a compactor are estimated again from their merged data.
+//
+// Unlike WithMemoryBudget, which flushes the queue early, this rejects new
+// jobs, and both can be used together.
+func WithMaxMemory[A any, B any](bytes int64, sizeOf func(A) int64) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.maxMemory = bytes
+		b.sizeOf = sizeOf
+	}
+}
+
+// WithDeadlineSplit splits each flushed batch in two when some of its jobs
+// are close to the deadline of the context they were added with by
+// AddJobContext: jobs with less than the threshold left before their
+// deadline are processed as a batch of their own, dispatched first, so a
+// slow call for the larger batch cannot make them miss their deadline. Each
+// job receives its result from the batch it was processed in, and each
+// batch is reported separately by the Flushed channel with the reason of
+// the flush. Jobs without a deadline are never split off.
+func WithDeadlineSplit[A any, B any](threshold time.Duration) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.deadlineSplit = threshold
+	}
+}
+
+// WithShutdownOnFatal shuts the Batcher down automatically when a job fails
+// with an error wrapping ErrFatal, for when a single job can reveal that
+// the downstream is permanently gone and processing further jobs is
+// pointless. New jobs are rejected with ErrShuttingDown from the moment the
+// fatal error is returned, and the jobs already queued are drained as for
+// Shutdown, with the Flushed channel closed once they have completed. The
+// job that triggered the shutdown is reported by FatalJob. Without this
+// option, ErrFatal is delivered like any other error.
+func WithShutdownOnFatal[A any, B any]() Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.shutdownOnFatal = true
+	}
+}
+
+// WithShutdownOrder sets the order the jobs remaining on the queue are
+// drained in when the Batcher shuts down, so that the most important jobs
+// are processed first should ShutdownContext cut the drain short. For
+// example, ShutdownByPriority drains jobs with the highest Job.Priority
+// first. Shutdown flushes the remaining jobs as a single batch in this
+// order, which is also the order they are handed to a worker pool or
+// executor. Jobs are drained in the order they were queued by default.
+func WithShutdownOrder[A any, B any](order ShutdownOrder) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.shutdownOrder = order
+	}
+}
+
+// WithHealthThresholds sets the limits at which Health reports the Batcher
+// as degraded or unhealthy, replacing the defaults. By default, the Batcher
+// is degraded once its oldest queued job is twice the flush frequency old,
+// a tenth of recent jobs have failed or the queue is 80% full, and is
+// unhealthy at ten times the frequency, half of recent jobs failing or a
+// full queue. Thresholds left at zero are not checked.
+func WithHealthThresholds[A any, B any](thresholds HealthThresholds) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.healthThresholds = thresholds
+	}
+}
+
+// WithBatchTransform applies the transform to the data of each batch once
+// it has been flushed and before it is processed, giving a single place for
+// batch wide optimisations, such as resolving values shared by the batch or
+// rewriting data into a canonical form. The transform must return one
+// element per job, as the element at each index replaces the data of the
+// job at that index, and the job's result is routed back by that position.
+// It must therefore not reorder, merge or drop elements; use WithDedupeKey
+// or WithCompactor to merge jobs instead. If the transform returns a
+// different number of elements, every job in the batch fails with
+// ErrBatchSizeMismatch without being processed.
+//
+// The transformed data is the job's data from then on, as seen by the
+// result handler and the WAL. The transform is called with the Batcher's
+// mutex held, so it should be quick.
+func WithBatchTransform[A any, B any](transform func([]A) []A) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.batchTransform = transform
+	}
+}
+
+// WithFlushFirstWhenIdle flushes a job immediately when it arrives at an
+// empty queue after no job has been added for at least the idle threshold,
+// rather than waiting for the batch to fill or the ticker, giving sporadic
+// traffic minimal latency. Jobs that arrive within the threshold of the
+// previous job are batched as normal, so batching resumes under load. The
+// first job added to the Batcher always counts as arriving after an idle
+// gap. Such flushes are reported by the Flushed channel with FlushFirst.
+func WithFlushFirstWhenIdle[A any, B any](threshold time.Duration) Option[A, B] {
+	return func(b *Batcher[A, B]) {
+		b.flushFirstAfter = threshold
+	}
+}
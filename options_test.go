@@ -0,0 +1,591 @@
+package microbatcher
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatcherMemoryBudgetFlushesEarly(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithMemoryBudget[string, string](30, 10))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	for i := 1; i <= 3; i++ {
+		_, err := b.AddJob(Job[string]{Id: i, Data: "foobar"})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	// Allow time for processing to occur.
+	time.Sleep(10 * time.Millisecond)
+
+	if b.Stats().Queued != 0 {
+		t.Error("queue was not flushed when memory budget was reached")
+	}
+}
+
+func TestBatcherMemoryBudgetIgnoresInFlightJobs(t *testing.T) {
+	release := make(chan struct{})
+	blocking := func(in string) string {
+		<-release
+		return strings.ToUpper(in)
+	}
+
+	b := NewBatcher(blocking, FIVE_MINUTES, 10, WithMemoryBudget[string, string](30, 10))
+
+	go b.Start()
+	defer b.Shutdown()
+	defer close(release)
+
+	for i := 1; i <= 4; i++ {
+		_, err := b.AddJob(Job[string]{Id: i, Data: "foobar"})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+
+		// Allow time for processing to occur.
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The first three jobs are flushed by the budget, while the fourth is
+	// left to accumulate rather than being flushed alone behind them.
+	stats := b.Stats()
+	if stats.Queued != 1 || stats.InFlight != 3 {
+		t.Error("in-flight jobs were counted against the memory budget")
+	}
+
+	if stats.EstimatedBytes != 40 {
+		t.Error("estimated size of queued and in-flight jobs was not reported")
+	}
+}
+
+func TestBatcherMemoryBudgetNotReached(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithMemoryBudget[string, string](30, 10))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	for i := 1; i <= 2; i++ {
+		_, err := b.AddJob(Job[string]{Id: i, Data: "foobar"})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	// Allow time for processing to occur.
+	time.Sleep(10 * time.Millisecond)
+
+	if b.Stats().Queued != 2 {
+		t.Error("queue flushed before memory budget was reached")
+	}
+}
+
+func TestBatcherMaxQueueSizeRejectsJobs(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithMaxQueueSize[string, string](2))
+
+	for i := 1; i <= 2; i++ {
+		_, err := b.AddJob(Job[string]{Id: i, Data: "foobar"})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	_, err := b.AddJob(Job[string]{Id: 3, Data: "foobar"})
+	if err != ErrQueueFull {
+		t.Error("added job to a full queue")
+	}
+}
+
+func TestBatcherOverflowBatcherAbsorbsJobs(t *testing.T) {
+	overflow := NewBatcher(uppercaseString, FIVE_MINUTES, 1)
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10,
+		WithMaxQueueSize[string, string](1),
+		WithOverflowBatcher(overflow),
+	)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	go overflow.Start()
+	defer overflow.Shutdown()
+
+	_, err := b.AddJob(Job[string]{Id: 1, Data: "hello world"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	res, err := b.AddJob(Job[string]{Id: 2, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 2 to the overflow batcher")
+	}
+
+	str, err := res.Get()
+	if err != nil || str != "FOOBAR" {
+		t.Error("failed to process overflow job correctly")
+	}
+
+	if b.Stats().Queued != 1 {
+		t.Error("incorrect number of jobs on the primary queue")
+	}
+}
+
+func TestBatcherOverflowCountsCacheMissOnce(t *testing.T) {
+	overflow := NewBatcher(uppercaseString, FIVE_MINUTES, 1,
+		WithResultCacheLRU[string, string](strings.ToLower, 10),
+	)
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10,
+		WithMaxQueueSize[string, string](1),
+		WithOverflowBatcher(overflow),
+		WithResultCacheLRU[string, string](strings.ToLower, 10),
+	)
+
+	for i, data := range []string{"hello world", "foobar"} {
+		_, err := b.AddJob(Job[string]{Id: i, Data: data})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	misses := b.Stats().CacheMisses + overflow.Stats().CacheMisses
+	if misses != 2 {
+		t.Error("cache miss of overflowed job was counted twice")
+	}
+
+	b.Shutdown()
+	overflow.Shutdown()
+}
+
+func TestBatcherDedupeKeyCollapsesJobs(t *testing.T) {
+	dropped := []int{}
+	onDrop := func(kept Job[string], drop Job[string]) {
+		dropped = append(dropped, drop.Id)
+	}
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 2, WithDedupeKey[string, string](strings.ToLower, onDrop))
+
+	resA, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	resB, err := b.AddJob(Job[string]{Id: 2, Data: "FooBar"})
+	if err != nil {
+		t.Error("failed to add job 2")
+	}
+
+	if len(b.jobs) != 1 {
+		t.Error("duplicate job was added to the queue")
+	}
+
+	if len(dropped) != 1 || dropped[0] != 2 {
+		t.Error("drop callback was not invoked for the duplicate job")
+	}
+
+	_, err = b.AddJob(Job[string]{Id: 3, Data: "baz"})
+	if err != nil {
+		t.Error("failed to add job 3")
+	}
+
+	go b.Start()
+	defer b.Shutdown()
+
+	aStr, err := resA.Get()
+	if err != nil || aStr != "FOOBAR" {
+		t.Error("failed to process job 1 correctly")
+	}
+
+	bStr, err := resB.Get()
+	if err != nil || bStr != "FOOBAR" {
+		t.Error("duplicate job did not receive the shared result")
+	}
+
+	if resB.JobId != 2 {
+		t.Error("duplicate job result has incorrect job id")
+	}
+}
+
+func TestBatcherMaxAgeFlushesOnlyOverdueJobs(t *testing.T) {
+	b := NewBatcher(uppercaseString, 10*time.Millisecond, 10, WithMaxAge[string, string](50*time.Millisecond))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	resA, err := b.AddJob(Job[string]{Id: 1, Data: "hello world"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	// Wait to allow job 1 to become overdue.
+	time.Sleep(70 * time.Millisecond)
+
+	_, err = b.AddJob(Job[string]{Id: 2, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 2")
+	}
+
+	// Wait to allow the ticker to fire again.
+	time.Sleep(20 * time.Millisecond)
+
+	aStr, err := resA.Get()
+	if err != nil || aStr != "HELLO WORLD" {
+		t.Error("failed to process overdue job correctly")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.jobs) != 1 {
+		t.Error("ticker flushed jobs that were not overdue")
+	}
+}
+
+func TestBatcherQueueCapacity(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 2, WithQueueCapacity[string, string](16))
+
+	if cap(b.jobs) != 16 {
+		t.Error("queue was not allocated with the configured capacity")
+	}
+
+	go b.Start()
+	defer b.Shutdown()
+
+	for i := 1; i <= 2; i++ {
+		_, err := b.AddJob(Job[string]{Id: i, Data: "foobar"})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	<-b.Flushed()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if cap(b.jobs) != 16 {
+		t.Error("queue was not reallocated with the configured capacity")
+	}
+}
+
+func TestBatcherResultHandler(t *testing.T) {
+	var mu sync.Mutex
+	handled := map[int]string{}
+
+	handler := func(job Job[string], res string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil || res != strings.ToUpper(job.Data) {
+			t.Errorf("incorrect result passed to handler for job %d", job.Id)
+		}
+
+		handled[job.Id] = res
+	}
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10,
+		WithResultHandler(handler),
+		WithDedupeKey[string, string](strings.ToLower, nil),
+	)
+
+	jobs := []Job[string]{
+		{Id: 1, Data: "hello world"},
+		{Id: 2, Data: "foobar"},
+		{Id: 3, Data: "FOOBAR"},
+	}
+
+	for i, job := range jobs {
+		_, err := b.AddJob(job)
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	go b.Start()
+	b.Shutdown()
+
+	if len(handled) != 3 || handled[3] != "FOOBAR" {
+		t.Error("result handler was not invoked for every job")
+	}
+}
+
+func TestBatcherDryRunSkipsProcessor(t *testing.T) {
+	processor := func(in string) string {
+		t.Error("processor called in dry run mode")
+		return in
+	}
+
+	b := NewBatcher(processor, FIVE_MINUTES, 2, WithDryRun[string, string](nil))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	results := []*JobResult[string]{}
+	for i := 1; i <= 2; i++ {
+		res, err := b.AddJob(Job[string]{Id: i, Data: "foobar"})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+
+		results = append(results, res)
+	}
+
+	for i, res := range results {
+		if str, err := res.Get(); err != nil || str != "" {
+			t.Errorf("dry run job %d did not receive a zero value", i)
+		}
+	}
+
+	info := <-b.Flushed()
+	if len(info.JobIds) != 2 || info.JobIds[0] != 1 || info.JobIds[1] != 2 {
+		t.Error("flush notification did not report the batch composition")
+	}
+}
+
+func TestBatcherDryRunStub(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 1, WithDryRun(strings.ToLower))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "FooBar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if str, err := res.Get(); err != nil || str != "foobar" {
+		t.Error("dry run job did not receive the stub result")
+	}
+}
+
+func TestBatcherIdleTimeoutFlushesAfterBurst(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithIdleTimeout[string, string](30*time.Millisecond))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	// Add a burst of jobs, each arriving before the idle timeout.
+	for i := 1; i <= 3; i++ {
+		_, err := b.AddJob(Job[string]{Id: i, Data: "foobar"})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+
+		time.Sleep(15 * time.Millisecond)
+	}
+
+	if b.Stats().Queued != 3 {
+		t.Error("queue flushed before the burst ended")
+	}
+
+	select {
+	case info := <-b.Flushed():
+		if info.Reason != FlushIdle || info.Size != 3 {
+			t.Error("queue was not flushed by the idle timeout")
+		}
+	case <-time.After(time.Second):
+		t.Error("queue was not flushed by the idle timeout")
+	}
+}
+
+func TestBatcherTimerCoalesceSkipsTickAfterSizeFlush(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 2, WithTimerCoalesce[string, string](time.Minute))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	for i := 1; i <= 3; i++ {
+		if _, err := b.AddJob(Job[string]{Id: i, Data: "foobar"}); err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	if info := <-b.Flushed(); info.Reason != FlushSize {
+		t.Error("queue was not flushed by size")
+	}
+
+	// A tick within the window after the size flush is skipped.
+	b.tick(time.Now())
+
+	if b.Stats().Queued != 1 {
+		t.Error("tick was not skipped within the coalesce window")
+	}
+
+	// A tick after the window flushes the remaining job.
+	b.tick(time.Now().Add(time.Minute))
+
+	if info := <-b.Flushed(); info.Reason != FlushTimer || info.Size != 1 {
+		t.Error("tick after the coalesce window did not flush the queue")
+	}
+}
+
+func TestBatcherTimerCoalesceDefaultWindow(t *testing.T) {
+	b := NewBatcher(uppercaseString, time.Second, 2, WithTimerCoalesce[string, string](0))
+	defer b.Shutdown()
+
+	if b.timerCoalesce != 100*time.Millisecond {
+		t.Error("coalesce window did not default to a tenth of the frequency")
+	}
+}
+
+func TestBatcherRequireStartRejectsJobsBeforeStart(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithRequireStart[string, string]())
+	defer b.Shutdown()
+
+	if _, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"}); !errors.Is(err, ErrNotStarted) {
+		t.Error("job was accepted before the batcher was started")
+	}
+
+	if err := b.Flush(); !errors.Is(err, ErrNotStarted) {
+		t.Error("flush was accepted before the batcher was started")
+	}
+}
+
+func TestBatcherRequireStartAcceptsJobsAfterStartAsync(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithRequireStart[string, string]())
+
+	b.StartAsync()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Fatal("failed to add job 1")
+	}
+
+	if err := b.Flush(); err != nil {
+		t.Error("failed to flush the started batcher")
+	}
+
+	if str, err := res.Get(); err != nil || str != "FOOBAR" {
+		t.Error("failed to process job 1 correctly")
+	}
+}
+
+func TestBatcherExecutorRunsProcessing(t *testing.T) {
+	tasks := make(chan func(), 10)
+
+	// A single worker runs every task handed to the executor.
+	worker := make(chan struct{})
+	go func() {
+		defer close(worker)
+
+		for task := range tasks {
+			task()
+		}
+	}()
+
+	var submitted atomic.Int32
+	executor := func(task func()) {
+		submitted.Add(1)
+		tasks <- task
+	}
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 2, WithExecutor[string, string](executor))
+
+	go b.Start()
+
+	results := addJobs(t, b, "hello world", "foobar")
+	expected := []string{"HELLO WORLD", "FOOBAR"}
+
+	for i, res := range results {
+		if str, err := res.Get(); err != nil || str != expected[i] {
+			t.Errorf("failed to process job %d correctly", i)
+		}
+	}
+
+	b.Shutdown()
+	close(tasks)
+	<-worker
+
+	if submitted.Load() != 2 {
+		t.Error("jobs were not processed by the executor")
+	}
+}
+
+func TestBatcherMaxMemoryRejectsJobs(t *testing.T) {
+	sizeOf := func(in string) int64 {
+		return int64(len(in))
+	}
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithMaxMemory[string, string](10, sizeOf))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	if _, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"}); err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if _, err := b.AddJob(Job[string]{Id: 2, Data: "foobar"}); !errors.Is(err, ErrMemoryLimit) {
+		t.Error("job over the memory limit was accepted")
+	}
+
+	// Smaller jobs that fit within the limit are still accepted.
+	if _, err := b.AddJob(Job[string]{Id: 3, Data: "baz"}); err != nil {
+		t.Error("failed to add job 3")
+	}
+
+	// Flushed jobs no longer count towards the limit.
+	b.Flush()
+
+	if _, err := b.AddJob(Job[string]{Id: 4, Data: "foobar"}); err != nil {
+		t.Error("memory was not released by the flush")
+	}
+
+	if _, err := b.AddJob(Job[string]{Id: 5, Data: "hello world"}); !errors.Is(err, ErrMemoryLimit) {
+		t.Error("job larger than the memory limit was accepted")
+	}
+}
+
+func TestBatcherFlushFirstWhenIdle(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithFlushFirstWhenIdle[string, string](20*time.Millisecond))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	for round := range 2 {
+		res, err := b.AddJob(Job[string]{Id: round, Data: "foobar"})
+		if err != nil {
+			t.Errorf("failed to add job %d", round)
+		}
+
+		if str, err := res.Get(); err != nil || str != "FOOBAR" {
+			t.Errorf("failed to process job %d", round)
+		}
+
+		if info := <-b.Flushed(); info.Reason != FlushFirst || info.Size != 1 {
+			t.Errorf("job %d was not flushed as the first after an idle gap", round)
+		}
+
+		time.Sleep(40 * time.Millisecond)
+	}
+}
+
+func TestBatcherFlushFirstWhenIdleBatchesUnderLoad(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithFlushFirstWhenIdle[string, string](time.Minute))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	if _, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"}); err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if info := <-b.Flushed(); info.Reason != FlushFirst {
+		t.Error("first job was not flushed immediately")
+	}
+
+	for i := 2; i <= 3; i++ {
+		if _, err := b.AddJob(Job[string]{Id: i, Data: "foobar"}); err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	if b.Stats().Queued != 2 {
+		t.Error("jobs arriving after the first were not batched")
+	}
+}
@@ -0,0 +1,171 @@
+package microbatcher
+
+import (
+	"sync"
+	"time"
+)
+
+// Partitioner assigns the data of each job to a partition, so that jobs
+// are only batched with others in the same partition. Implementations may
+// hold state, such as a consistent hash ring over a changing set of shards,
+// but must be safe to call concurrently.
+type Partitioner[A any, K comparable] interface {
+	Partition(data A) K
+}
+
+// PartitionFunc adapts a function to a Partitioner.
+type PartitionFunc[A any, K comparable] func(data A) K
+
+// Partition returns the partition of the data.
+func (f PartitionFunc[A, K]) Partition(data A) K {
+	return f(data)
+}
+
+// PartitionedBatcher batches jobs separately for each partition, with a
+// Batcher per partition key.
+//
+// The Batcher for a partition is created and started when the first job for
+// it is added, and shut down once it has been idle, with no jobs added and
+// none queued, for the idle timeout, so that partitions for keys that are no
+// longer in use do not accumulate. A later job for the same key creates a
+// new Batcher.
+type PartitionedBatcher[A any, B any, K comparable] struct {
+	partitioner Partitioner[A, K]
+	// Function that constructs the Batcher for a new partition.
+	newBatcher func(key K) *Batcher[A, B]
+	// Duration after which an idle partition is shut down, never when
+	// zero.
+	idleTimeout time.Duration
+	partitions  map[K]*partition[A, B]
+	shutting    bool
+	done        chan struct{}
+	reaper      sync.WaitGroup
+	mu          sync.Mutex
+}
+
+// partition is the Batcher for a single partition key.
+type partition[A any, B any] struct {
+	batcher *Batcher[A, B]
+	// Time a job was last added to the partition.
+	lastUsed time.Time
+}
+
+// NewPartitionedBatcher constructs a new PartitionedBatcher, assigning jobs
+// to partitions with the partitioner and creating the Batcher for each new
+// partition with newBatcher. Partitions idle for the idle timeout are shut
+// down, or never if it is zero.
+func NewPartitionedBatcher[A any, B any, K comparable](partitioner Partitioner[A, K], newBatcher func(key K) *Batcher[A, B], idleTimeout time.Duration) *PartitionedBatcher[A, B, K] {
+	pb := &PartitionedBatcher[A, B, K]{
+		partitioner: partitioner,
+		newBatcher:  newBatcher,
+		idleTimeout: idleTimeout,
+		partitions:  map[K]*partition[A, B]{},
+		done:        make(chan struct{}),
+	}
+
+	if idleTimeout > 0 {
+		pb.reaper.Add(1)
+		go pb.reapIdle()
+	}
+
+	return pb
+}
+
+// AddJob adds the job to the Batcher for its partition, creating and
+// starting the Batcher if the partition does not yet have one. An error is
+// returned if the PartitionedBatcher is shutting down, or the job is
+// rejected by the Batcher of its partition.
+func (pb *PartitionedBatcher[A, B, K]) AddJob(job Job[A]) (*JobResult[B], error) {
+	key := pb.partitioner.Partition(job.Data)
+
+	// The mutex is held while the job is added, so that the partition
+	// cannot be reaped in the meantime.
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	if pb.shutting {
+		return nil, ErrShuttingDown
+	}
+
+	p, ok := pb.partitions[key]
+	if !ok {
+		p = &partition[A, B]{batcher: pb.newBatcher(key)}
+		p.batcher.StartAsync()
+		pb.partitions[key] = p
+	}
+
+	p.lastUsed = time.Now()
+
+	return p.batcher.AddJob(job)
+}
+
+// Partitions returns the number of partitions that currently have a
+// Batcher.
+func (pb *PartitionedBatcher[A, B, K]) Partitions() int {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	return len(pb.partitions)
+}
+
+// Shutdown gracefully shuts down the Batcher of every partition, flushing
+// their remaining jobs, and stops new jobs from being added.
+func (pb *PartitionedBatcher[A, B, K]) Shutdown() {
+	pb.mu.Lock()
+
+	if pb.shutting {
+		pb.mu.Unlock()
+		return
+	}
+
+	pb.shutting = true
+	partitions := pb.partitions
+	pb.partitions = map[K]*partition[A, B]{}
+
+	pb.mu.Unlock()
+
+	close(pb.done)
+	pb.reaper.Wait()
+
+	for _, p := range partitions {
+		p.batcher.Shutdown()
+	}
+}
+
+// reapIdle periodically shuts down the partitions that have been idle for
+// the idle timeout, until the PartitionedBatcher shuts down.
+func (pb *PartitionedBatcher[A, B, K]) reapIdle() {
+	defer pb.reaper.Done()
+
+	ticker := time.NewTicker(pb.idleTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pb.done:
+			return
+		case now := <-ticker.C:
+			for _, p := range pb.removeIdle(now) {
+				p.batcher.Shutdown()
+			}
+		}
+	}
+}
+
+// removeIdle removes and returns the partitions that have had no jobs added
+// for the idle timeout and have none queued.
+func (pb *PartitionedBatcher[A, B, K]) removeIdle(now time.Time) []*partition[A, B] {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+
+	idle := []*partition[A, B]{}
+
+	for key, p := range pb.partitions {
+		if now.Sub(p.lastUsed) >= pb.idleTimeout && p.batcher.Stats().Queued == 0 {
+			idle = append(idle, p)
+			delete(pb.partitions, key)
+		}
+	}
+
+	return idle
+}
@@ -0,0 +1,89 @@
+package microbatcher
+
+import (
+	"testing"
+	"time"
+)
+
+// firstLetter partitions strings by their first letter.
+var firstLetter = PartitionFunc[string, byte](func(in string) byte {
+	return in[0]
+})
+
+func TestPartitionedBatcherBatchesPerPartition(t *testing.T) {
+	newBatcher := func(key byte) *Batcher[string, string] {
+		return NewBatcher(uppercaseString, FIVE_MINUTES, 2)
+	}
+
+	pb := NewPartitionedBatcher(firstLetter, newBatcher, 0)
+
+	results := []*JobResult[string]{}
+	for i, str := range []string{"apple", "banana", "avocado"} {
+		res, err := pb.AddJob(Job[string]{Id: i, Data: str})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+
+		results = append(results, res)
+	}
+
+	if pb.Partitions() != 2 {
+		t.Error("jobs were not assigned to a partition per key")
+	}
+
+	// The full partition is flushed, while the other waits for its batch.
+	if str, err := results[0].Get(); err != nil || str != "APPLE" {
+		t.Error("failed to process job 0 correctly")
+	}
+
+	if str, err := results[2].Get(); err != nil || str != "AVOCADO" {
+		t.Error("failed to process job 2 correctly")
+	}
+
+	pb.Shutdown()
+
+	if str, err := results[1].Get(); err != nil || str != "BANANA" {
+		t.Error("remaining job was not flushed on shutdown")
+	}
+
+	if _, err := pb.AddJob(Job[string]{Id: 3, Data: "cherry"}); err != ErrShuttingDown {
+		t.Error("job was added after shutdown")
+	}
+}
+
+func TestPartitionedBatcherReapsIdlePartitions(t *testing.T) {
+	newBatcher := func(key byte) *Batcher[string, string] {
+		return NewBatcher(uppercaseString, FIVE_MINUTES, 1)
+	}
+
+	pb := NewPartitionedBatcher(firstLetter, newBatcher, 10*time.Millisecond)
+	defer pb.Shutdown()
+
+	res, err := pb.AddJob(Job[string]{Id: 1, Data: "apple"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if _, err := res.Get(); err != nil {
+		t.Error("failed to process job 1")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for pb.Partitions() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("idle partition was not reaped")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	// A later job for the same key creates a new partition.
+	res, err = pb.AddJob(Job[string]{Id: 2, Data: "apricot"})
+	if err != nil {
+		t.Error("failed to add job 2")
+	}
+
+	if str, err := res.Get(); err != nil || str != "APRICOT" {
+		t.Error("failed to process job 2 after its partition was reaped")
+	}
+}
@@ -0,0 +1,87 @@
+package microbatcher
+
+import "sync"
+
+// workerPool runs tasks on a resizable set of worker goroutines. Tasks are
+// queued without blocking, so they can be submitted with the Batcher's
+// mutex held.
+type workerPool struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// Tasks waiting for a worker.
+	tasks []func()
+	// Number of running workers, and the number there should be.
+	workers int
+	size    int
+	closed  bool
+}
+
+func newWorkerPool(size int) *workerPool {
+	p := &workerPool{}
+	p.cond = sync.NewCond(&p.mu)
+	p.resize(size)
+
+	return p
+}
+
+// submit queues the task to be run by a worker.
+func (p *workerPool) submit(task func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.tasks = append(p.tasks, task)
+	p.cond.Signal()
+}
+
+// resize grows or shrinks the pool to the given number of workers. New
+// workers are started immediately, while excess workers exit once they
+// have finished their current task.
+func (p *workerPool) resize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.size = size
+
+	for ; p.workers < p.size; p.workers++ {
+		go p.work()
+	}
+
+	p.cond.Broadcast()
+}
+
+// close stops the workers once every queued task has been run.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	p.cond.Broadcast()
+}
+
+// work runs queued tasks until the pool shrinks below this worker, or is
+// closed with no tasks remaining.
+func (p *workerPool) work() {
+	p.mu.Lock()
+
+	for {
+		if p.workers > p.size || (p.closed && len(p.tasks) == 0) {
+			p.workers--
+			p.mu.Unlock()
+
+			return
+		}
+
+		if len(p.tasks) == 0 {
+			p.cond.Wait()
+			continue
+		}
+
+		task := p.tasks[0]
+		p.tasks[0] = nil
+		p.tasks = p.tasks[1:]
+
+		p.mu.Unlock()
+		task()
+		p.mu.Lock()
+	}
+}
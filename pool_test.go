@@ -0,0 +1,115 @@
+package microbatcher
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingProcessor returns a processor that blocks until released,
+// tracking the number of calls running at once.
+func blockingProcessor(release <-chan struct{}, running *atomic.Int32) func(string) string {
+	return func(in string) string {
+		running.Add(1)
+		defer running.Add(-1)
+
+		<-release
+
+		return strings.ToUpper(in)
+	}
+}
+
+// waitForRunning waits until n processor calls are running at once.
+func waitForRunning(t *testing.T, running *atomic.Int32, n int32) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for running.Load() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d processor calls were running, expected %d", running.Load(), n)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBatcherConcurrencyLimitsProcessing(t *testing.T) {
+	release := make(chan struct{})
+	var running atomic.Int32
+
+	b := NewBatcher(blockingProcessor(release, &running), FIVE_MINUTES, 6, WithConcurrency[string, string](2))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	results := addJobs(t, b, "a", "b", "c", "d", "e", "f")
+
+	waitForRunning(t, &running, 2)
+
+	// Allow time for any excess calls to start.
+	time.Sleep(10 * time.Millisecond)
+
+	if running.Load() != 2 {
+		t.Error("processing was not limited to the configured concurrency")
+	}
+
+	close(release)
+
+	for i, res := range results {
+		if _, err := res.Get(); err != nil {
+			t.Errorf("failed to process job %d", i)
+		}
+	}
+}
+
+func TestBatcherSetConcurrency(t *testing.T) {
+	release := make(chan struct{})
+	var running atomic.Int32
+
+	b := NewBatcher(blockingProcessor(release, &running), FIVE_MINUTES, 6, WithConcurrency[string, string](1))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	results := addJobs(t, b, "a", "b", "c", "d", "e", "f")
+
+	waitForRunning(t, &running, 1)
+
+	if err := b.SetConcurrency(3); err != nil {
+		t.Fatal("failed to grow the worker pool")
+	}
+
+	waitForRunning(t, &running, 3)
+
+	if b.Config().Concurrency != 3 {
+		t.Error("config did not reflect the new concurrency")
+	}
+
+	// Shrinking lets the running calls finish without dropping any jobs.
+	if err := b.SetConcurrency(1); err != nil {
+		t.Fatal("failed to shrink the worker pool")
+	}
+
+	close(release)
+
+	for i, res := range results {
+		if _, err := res.Get(); err != nil {
+			t.Errorf("failed to process job %d", i)
+		}
+	}
+
+	if err := b.SetConcurrency(0); err != ErrInvalidConcurrency {
+		t.Error("concurrency was set to zero")
+	}
+}
+
+func TestBatcherConfig(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithMaxQueueSize[string, string](100))
+	defer b.Shutdown()
+
+	config := b.Config()
+	if config.BatchSize != 10 || config.Frequency != FIVE_MINUTES || config.MaxQueueSize != 100 || config.Concurrency != 0 {
+		t.Errorf("config did not reflect the batcher, got %+v", config)
+	}
+}
@@ -0,0 +1,86 @@
+package microbatcher
+
+import "slices"
+
+// dequeueWeighted removes and returns n jobs from the queue, drawn from the
+// priority classes in proportion to their weights. The mutex must be held.
+func (b *Batcher[A, B]) dequeueWeighted(n int) []*batchJob[A, B] {
+	batch, rest := b.selectWeighted(n, b.credits)
+	b.jobs = rest
+
+	b.release(batch)
+
+	return batch
+}
+
+// selectWeighted selects n jobs from the queue using smooth weighted round
+// robin across the priority classes with queued jobs: on each pick, every
+// such class is credited its weight and the class with the most credit
+// provides its oldest job, paying back the total weight credited. Carrying
+// the credits over between batches keeps the long run share of each class
+// proportional to its weight, while no class with queued jobs is starved.
+//
+// The selected jobs are returned in queue order, along with the jobs left
+// on the queue. Ties are won by the higher priority. The mutex must be held.
+func (b *Batcher[A, B]) selectWeighted(n int, credits map[int]int) (selected []*batchJob[A, B], rest []*batchJob[A, B]) {
+	classes := map[int][]int{}
+	for i, job := range b.jobs {
+		classes[job.job.Priority] = append(classes[job.job.Priority], i)
+	}
+
+	priorities := make([]int, 0, len(classes))
+	for p := range classes {
+		priorities = append(priorities, p)
+	}
+
+	// Visit the highest priority first, so that it wins ties.
+	slices.Sort(priorities)
+	slices.Reverse(priorities)
+
+	picked := make([]bool, len(b.jobs))
+
+	for range n {
+		best, total := 0, 0
+		found := false
+
+		for _, p := range priorities {
+			if len(classes[p]) == 0 {
+				continue
+			}
+
+			weight := b.weight(p)
+			credits[p] += weight
+			total += weight
+
+			if !found || credits[p] > credits[best] {
+				best, found = p, true
+			}
+		}
+
+		credits[best] -= total
+		picked[classes[best][0]] = true
+		classes[best] = classes[best][1:]
+	}
+
+	rest = make([]*batchJob[A, B], 0, max(b.queueCapacity, len(b.jobs)-n))
+
+	for i, job := range b.jobs {
+		if picked[i] {
+			selected = append(selected, job)
+		} else {
+			rest = append(rest, job)
+		}
+	}
+
+	return selected, rest
+}
+
+// weight returns the weight of the priority class, defaulting to one for
+// classes without a positive weight configured.
+func (b *Batcher[A, B]) weight(priority int) int {
+	if w := b.weights[priority]; w > 0 {
+		return w
+	}
+
+	return 1
+}
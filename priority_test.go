@@ -0,0 +1,98 @@
+package microbatcher
+
+import (
+	"slices"
+	"testing"
+)
+
+// addPriorityJobs queues count jobs of the priority class, with ids
+// starting from first.
+func addPriorityJobs(t *testing.T, b *Batcher[string, string], priority, first, count int) {
+	t.Helper()
+
+	for id := first; id < first+count; id++ {
+		if _, err := b.AddJob(Job[string]{Id: id, Data: "foobar", Priority: priority}); err != nil {
+			t.Errorf("failed to add job %d", id)
+		}
+	}
+}
+
+func TestBatcherWeightedPriorityThroughputRatio(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 4, WithWeightedPriority[string, string](map[int]int{1: 3, 0: 1}))
+	defer b.Shutdown()
+
+	addPriorityJobs(t, b, 1, 0, 40)
+	addPriorityJobs(t, b, 0, 100, 40)
+
+	counts := map[int]int{}
+	next := map[int]int{1: 0, 0: 100}
+
+	b.lock()
+	for range 10 {
+		for _, job := range b.dequeue(4) {
+			p := job.job.Priority
+			if job.job.Id != next[p] {
+				t.Errorf("jobs of priority %d were not taken in queue order", p)
+			}
+
+			counts[p]++
+			next[p]++
+		}
+	}
+	b.unlock()
+
+	if counts[1] != 30 || counts[0] != 10 {
+		t.Errorf("batches were not composed by weight, got %v", counts)
+	}
+}
+
+func TestBatcherWeightedPriorityFillsFromOtherClasses(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 4, WithWeightedPriority[string, string](map[int]int{1: 3, 0: 1}))
+	defer b.Shutdown()
+
+	addPriorityJobs(t, b, 1, 0, 1)
+	addPriorityJobs(t, b, 0, 100, 5)
+
+	b.lock()
+	batch := b.dequeue(4)
+	b.unlock()
+
+	ids := []int{}
+	for _, job := range batch {
+		ids = append(ids, job.job.Id)
+	}
+
+	if !slices.Equal(ids, []int{0, 100, 101, 102}) {
+		t.Errorf("batch was not filled from the remaining class, got %v", ids)
+	}
+}
+
+func TestBatcherWeightedPriorityPeekBatch(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 1, WithWeightedPriority[string, string](map[int]int{1: 2, 0: 1}))
+	defer b.Shutdown()
+
+	for i := range 3 {
+		addJobs(t, b, "low")
+		if _, err := b.AddJob(Job[string]{Id: i, Data: "high", Priority: 1}); err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	// Weights of 2 and 1 select high, low, then high again. Peeking
+	// repeatedly must not consume the credit that decides the order.
+	for _, expected := range []string{"high", "low", "high"} {
+		for range 2 {
+			if peeked := b.PeekBatch(); len(peeked) != 1 || peeked[0] != expected {
+				t.Fatalf("peeked %v, expected %s", peeked, expected)
+			}
+		}
+
+		b.lock()
+		batch := b.dequeue(1)
+		b.unlock()
+
+		if batch[0].job.Data != expected {
+			t.Fatalf("dequeued %s, expected %s", batch[0].job.Data, expected)
+		}
+	}
+}
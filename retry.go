@@ -0,0 +1,122 @@
+package microbatcher
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// PermanentError wraps an error returned by a processor to indicate that
+// the job can never succeed, so should not be retried. Callers can detect
+// permanent failures on the error returned by JobResult.Get with errors.As.
+type PermanentError struct {
+	Err error
+}
+
+// Permanent wraps err in a PermanentError.
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// process runs the processor against the data, retrying failed attempts
+// as configured until the context is done. In dry run mode the processor is
+// never called.
+func (b *Batcher[A, B]) process(ctx context.Context, data A) (B, error) {
+	if b.dryRun {
+		return b.stub(data), nil
+	}
+
+	var res B
+
+	err := b.retry(ctx, func() (err error) {
+		if err = b.injectFaults(data); err != nil {
+			return err
+		}
+
+		res, err = b.processor(data)
+		return err
+	})
+
+	return res, err
+}
+
+// processBatchData runs the batch processor against the data of a batch
+// with the batch's context, retrying failed attempts as configured. In dry
+// run mode the batch processor is never called.
+func (b *Batcher[A, B]) processBatchData(ctx context.Context, data []A) ([]B, error) {
+	if b.dryRun {
+		res := make([]B, len(data))
+		for i := range data {
+			res[i] = b.stub(data[i])
+		}
+
+		return res, nil
+	}
+
+	var res []B
+
+	err := b.retry(ctx, func() (err error) {
+		if err = b.injectFaults(data...); err != nil {
+			return err
+		}
+
+		res, err = b.batchProcessor(ctx, data)
+		return err
+	})
+
+	return res, err
+}
+
+// retry calls attempt until it succeeds, fails permanently or fatally, runs
+// out of attempts or the context is done, returning the error of the final
+// attempt.
+func (b *Batcher[A, B]) retry(ctx context.Context, attempt func() error) error {
+	err := attempt()
+
+	for attempts := 1; err != nil && attempts < b.maxAttempts; attempts++ {
+		var permanent *PermanentError
+		if errors.As(err, &permanent) || errors.Is(err, ErrFatal) {
+			break
+		}
+
+		if !sleep(ctx, b.retryBackoff) {
+			break
+		}
+
+		err = attempt()
+	}
+
+	return err
+}
+
+// sleep pauses for the duration, returning false without waiting it out if
+// the context is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// stub returns the dry run result for the data.
+func (b *Batcher[A, B]) stub(data A) B {
+	var res B
+	if b.dryRunStub != nil {
+		res = b.dryRunStub(data)
+	}
+
+	return res
+}
@@ -0,0 +1,95 @@
+package microbatcher
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var errTransient = errors.New("transient failure")
+
+func TestBatcherRetriesFailedJobs(t *testing.T) {
+	var calls atomic.Int32
+	processor := func(in string) (string, error) {
+		if calls.Add(1) < 3 {
+			return "", errTransient
+		}
+
+		return strings.ToUpper(in), nil
+	}
+
+	b := NewFallibleBatcher(processor, FIVE_MINUTES, 1, WithRetry[string, string](3, ONE_MILLISECOND))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	str, err := res.Get()
+	if err != nil || str != "FOOBAR" {
+		t.Error("failed job was not retried")
+	}
+
+	if calls.Load() != 3 {
+		t.Error("incorrect number of attempts")
+	}
+}
+
+func TestBatcherRetryDeliversErrorWhenExhausted(t *testing.T) {
+	var calls atomic.Int32
+	processor := func(in string) (string, error) {
+		calls.Add(1)
+		return "", errTransient
+	}
+
+	b := NewFallibleBatcher(processor, FIVE_MINUTES, 1, WithRetry[string, string](2, ONE_MILLISECOND))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if _, err := res.Get(); !errors.Is(err, errTransient) {
+		t.Error("processor error was not delivered")
+	}
+
+	if calls.Load() != 2 {
+		t.Error("incorrect number of attempts")
+	}
+}
+
+func TestBatcherDoesNotRetryPermanentError(t *testing.T) {
+	var calls atomic.Int32
+	processor := func(in string) (string, error) {
+		calls.Add(1)
+		return "", Permanent(errTransient)
+	}
+
+	b := NewFallibleBatcher(processor, FIVE_MINUTES, 1, WithRetry[string, string](3, ONE_MILLISECOND))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	_, err = res.Get()
+
+	var permanent *PermanentError
+	if !errors.As(err, &permanent) || !errors.Is(err, errTransient) {
+		t.Error("permanent error was not delivered")
+	}
+
+	if calls.Load() != 1 {
+		t.Error("permanent error was retried")
+	}
+}
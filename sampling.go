@@ -0,0 +1,10 @@
+package microbatcher
+
+import "math/rand/v2"
+
+// sample decides whether a new job is sampled for logging. The decision is
+// made once when the job is added, so a sampled job is logged at every
+// stage of its lifecycle.
+func (b *Batcher[A, B]) sample() bool {
+	return b.logFraction > 0 && rand.Float64() < b.logFraction
+}
@@ -0,0 +1,94 @@
+package microbatcher
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// captureLogs directs the default logger to a buffer for the rest of the
+// test, returning a function that reads the lines logged so far.
+func captureLogs(t *testing.T) func() []string {
+	var mu sync.Mutex
+	var buf bytes.Buffer
+
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&lockedWriter{mu: &mu, w: &buf}, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return strings.Split(strings.TrimSpace(buf.String()), "\n")
+	}
+}
+
+// lockedWriter serialises writes to the underlying writer.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  *bytes.Buffer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.w.Write(p)
+}
+
+func TestBatcherSampledLoggingIsStablePerJob(t *testing.T) {
+	logs := captureLogs(t)
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithSampledLogging[string, string](0.5))
+
+	go b.Start()
+
+	for i := 0; i < 100; i++ {
+		if _, err := b.AddJob(Job[string]{Id: i, Data: "foobar"}); err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	b.Shutdown()
+
+	stages := map[string]int{}
+	for _, line := range logs() {
+		for _, field := range strings.Fields(line) {
+			if strings.HasPrefix(field, "job_id=") {
+				stages[field]++
+			}
+		}
+	}
+
+	if len(stages) == 0 || len(stages) == 100 {
+		t.Errorf("jobs were not sampled, %d of 100 logged", len(stages))
+	}
+
+	// A sampled job is logged when queued, flushed and completed.
+	for id, n := range stages {
+		if n != 3 {
+			t.Errorf("job %s was logged at %d of 3 stages", id, n)
+		}
+	}
+
+	if b.Stats().Completed != 100 {
+		t.Error("counters did not cover every job")
+	}
+}
+
+func TestBatcherSampledLoggingDisabled(t *testing.T) {
+	logs := captureLogs(t)
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	go b.Start()
+	addJobs(t, b, "foobar")
+	b.Shutdown()
+
+	if lines := logs(); len(lines) != 1 || lines[0] != "" {
+		t.Errorf("jobs were logged without sampling, got %q", lines)
+	}
+}
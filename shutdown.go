@@ -0,0 +1,181 @@
+package microbatcher
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// ShutdownOrder is the order the jobs remaining on the queue are drained in
+// when the Batcher shuts down.
+type ShutdownOrder int
+
+const (
+	// ShutdownFIFO drains jobs in the order they were queued.
+	ShutdownFIFO ShutdownOrder = iota
+	// ShutdownByPriority drains jobs with the highest Job.Priority first,
+	// in the order they were queued within each priority.
+	ShutdownByPriority
+	// ShutdownByDeadline drains jobs with the earliest deadline on the
+	// context they were added with first, followed by jobs without a
+	// deadline in the order they were queued.
+	ShutdownByDeadline
+)
+
+// ShutdownContext gracefully shuts down the Batcher like Shutdown, but
+// drains the remaining jobs a batch at a time in the order configured by
+// WithShutdownOrder, waiting for each batch to complete before flushing the
+// next. If the context is done before the drain finishes, the jobs still on
+// the queue are completed with the context's error without being
+// processed, the context passed to the batch or streaming processor for
+// the batches already flushed is cancelled, and the context's error is
+// returned without waiting for those batches, which complete in the
+// background. The Flushed channel is closed once they have.
+//
+// If the Batcher is already shutting down, ShutdownContext waits for that
+// shutdown to finish or the context to be done.
+func (b *Batcher[A, B]) ShutdownContext(ctx context.Context) error {
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+
+		b.shutdownOnce.Do(func() {
+			b.shutdown(ctx)
+		})
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// dequeueForShutdown removes and returns up to n jobs from the queue,
+// taking the jobs first in the shutdown order, which the batch is ordered
+// by. The jobs left on the queue stay in the order they were added, which
+// the oldest pending age and queue expiry rely on. The mutex must be held.
+func (b *Batcher[A, B]) dequeueForShutdown(n int) []*batchJob[A, B] {
+	if b.shutdownOrder == ShutdownFIFO {
+		return b.dequeueHead(n)
+	}
+
+	ordered := slices.Clone(b.jobs)
+	slices.SortStableFunc(ordered, b.compareForShutdown)
+
+	batch := ordered[:min(n, len(ordered))]
+
+	taken := make(map[*batchJob[A, B]]bool, len(batch))
+	for _, job := range batch {
+		taken[job] = true
+	}
+
+	rest := make([]*batchJob[A, B], 0, b.queueCapacity)
+	for _, job := range b.jobs {
+		if !taken[job] {
+			rest = append(rest, job)
+		}
+	}
+
+	b.jobs = rest
+	b.release(batch)
+
+	return batch
+}
+
+// compareForShutdown compares two jobs by the shutdown order, so that the
+// job to drain first sorts first.
+func (b *Batcher[A, B]) compareForShutdown(x, y *batchJob[A, B]) int {
+	switch b.shutdownOrder {
+	case ShutdownByPriority:
+		return cmp.Compare(y.job.Priority, x.job.Priority)
+	case ShutdownByDeadline:
+		xd, xok := x.job.Context().Deadline()
+		yd, yok := y.job.Context().Deadline()
+
+		switch {
+		case xok && yok:
+			return xd.Compare(yd)
+		case xok:
+			return -1
+		case yok:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// drain flushes the jobs remaining on the queue a batch at a time, waiting
+// for each batch to complete before flushing the next, until the queue is
+// empty or the context is done. The drained jobs are returned, along with
+// an error describing the jobs abandoned if the drain was cut short. The
+// processing loops must have stopped.
+func (b *Batcher[A, B]) drain(ctx context.Context) ([]*batchJob[A, B], error) {
+	var drained []*batchJob[A, B]
+
+	for {
+		b.lock()
+		batch := b.dequeueForShutdown(b.batchSize)
+		completed := b.processBatch(batch, FlushShutdown)
+		b.unlock()
+
+		if completed == nil {
+			return drained, nil
+		}
+
+		drained = append(drained, batch...)
+
+		select {
+		case <-completed:
+		case <-ctx.Done():
+			if n := b.abandon(ctx.Err()); n > 0 {
+				return drained, fmt.Errorf("%d jobs abandoned: %w", n, ctx.Err())
+			}
+
+			return drained, nil
+		}
+	}
+}
+
+// abort cancels the context of every batch still being processed, once the
+// shutdown drain has been cut short.
+func (b *Batcher[A, B]) abort() {
+	b.abortOnce.Do(func() {
+		close(b.aborted)
+	})
+}
+
+// abandon completes every job remaining on the queue with the error,
+// without processing them, returning the number of jobs abandoned. The
+// jobs are not marked done in the write-ahead log.
+func (b *Batcher[A, B]) abandon(err error) int {
+	b.lock()
+	abandoned := b.dequeueHead(len(b.jobs))
+	b.stats.inFlight.Add(int64(len(abandoned)))
+	b.unlock()
+
+	var zero B
+	for _, job := range abandoned {
+		job.unprocessed = true
+		b.complete(job, zero, err)
+	}
+
+	return len(abandoned)
+}
+
+// jobErrors returns the error of each failed job in the batch, wrapped with
+// the id of its job. The jobs must have completed.
+func jobErrors[A any, B any](batch []*batchJob[A, B]) []error {
+	var errs []error
+	for _, job := range batch {
+		if job.err != nil {
+			errs = append(errs, fmt.Errorf("job %d: %w", job.job.Id, job.err))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,171 @@
+package microbatcher
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func addPrioritised(t *testing.T, b *Batcher[int, int], priorities ...int) []*JobResult[int] {
+	results := []*JobResult[int]{}
+
+	for i, p := range priorities {
+		res, err := b.AddJob(Job[int]{Id: i, Data: p, Priority: p})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+
+		results = append(results, res)
+	}
+
+	return results
+}
+
+func TestBatcherShutdownContextDrainsByPriority(t *testing.T) {
+	var mu sync.Mutex
+	processed := []int{}
+	processor := func(p int) int {
+		mu.Lock()
+		defer mu.Unlock()
+
+		processed = append(processed, p)
+
+		return p
+	}
+
+	b := NewBatcher(processor, FIVE_MINUTES, 1, WithShutdownOrder[int, int](ShutdownByPriority))
+
+	// The Batcher is not started, so every job is left for the shutdown.
+	addPrioritised(t, b, 0, 2, 1, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := b.ShutdownContext(ctx); err != nil {
+		t.Error("shutdown did not finish before the deadline")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !slices.Equal(processed, []int{2, 2, 1, 0}) {
+		t.Errorf("jobs were drained in the wrong order: %v", processed)
+	}
+}
+
+func TestBatcherShutdownContextCutShort(t *testing.T) {
+	release := make(chan struct{})
+	processor := func(p int) int {
+		<-release
+		return p
+	}
+
+	b := NewBatcher(processor, FIVE_MINUTES, 1, WithShutdownOrder[int, int](ShutdownByPriority))
+	results := addPrioritised(t, b, 0, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := b.ShutdownContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("shutdown did not return the context error")
+	}
+
+	if _, err := results[0].Get(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("low priority job was not abandoned")
+	}
+
+	close(release)
+
+	if p, err := results[1].Get(); err != nil || p != 1 {
+		t.Error("high priority job was not processed")
+	}
+
+	for range b.Flushed() {
+	}
+
+	if err := b.Close(); !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "1 jobs abandoned") {
+		t.Errorf("close did not report the abandoned jobs: %v", err)
+	}
+}
+
+func TestBatcherShutdownOrdersFinalBatch(t *testing.T) {
+	b := NewBatcher(func(p int) int { return p }, FIVE_MINUTES, 10, WithShutdownOrder[int, int](ShutdownByPriority))
+
+	go b.Start()
+
+	addPrioritised(t, b, 0, 1, 2)
+
+	b.Shutdown()
+
+	info, ok := <-b.Flushed()
+	if !ok || !slices.Equal(info.JobIds, []int{2, 1, 0}) {
+		t.Error("final batch was not ordered by priority")
+	}
+}
+
+func TestBatcherShutdownByDeadline(t *testing.T) {
+	b := NewBatcher(func(p int) int { return p }, FIVE_MINUTES, 10, WithShutdownOrder[int, int](ShutdownByDeadline))
+
+	go b.Start()
+
+	later, cancelLater := context.WithTimeout(context.Background(), FIVE_MINUTES)
+	defer cancelLater()
+
+	sooner, cancelSooner := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelSooner()
+
+	for i, ctx := range []context.Context{context.Background(), later, sooner} {
+		if _, err := b.AddJobContext(ctx, Job[int]{Id: i, Data: i}); err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	b.Shutdown()
+
+	info, ok := <-b.Flushed()
+	if !ok || !slices.Equal(info.JobIds, []int{2, 1, 0}) {
+		t.Error("final batch was not ordered by deadline")
+	}
+}
+
+func TestBatcherShutdownContextKeepsQueueOrder(t *testing.T) {
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	processor := func(p int) int {
+		started <- struct{}{}
+		<-release
+
+		return p
+	}
+
+	b := NewBatcher(processor, FIVE_MINUTES, 1, WithShutdownOrder[int, int](ShutdownByPriority))
+
+	addPrioritised(t, b, 0)
+	time.Sleep(20 * time.Millisecond)
+	addPrioritised(t, b, 1, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	finished := make(chan error)
+	go func() {
+		finished <- b.ShutdownContext(ctx)
+	}()
+
+	<-started
+
+	// The lowest priority job is the oldest, even though it is drained last.
+	if age := b.OldestPendingAge(); age < 20*time.Millisecond {
+		t.Errorf("oldest pending age did not report the oldest job: %v", age)
+	}
+
+	close(release)
+
+	if err := <-finished; err != nil {
+		t.Error("shutdown did not finish before the deadline")
+	}
+}
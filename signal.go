@@ -0,0 +1,50 @@
+package microbatcher
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+)
+
+// FlushOnSignal flushes the Batcher each time one of the given signals is
+// received, such as SIGUSR1 for operational control. At least one signal
+// must be given, so that other signals such as SIGINT are left alone. The
+// signal handler is unregistered once the Batcher shuts down. As there is
+// no caller to return it to, an error from Flush, such as ErrNotStarted, is
+// logged with the default slog logger.
+func FlushOnSignal[A any, B any](b *Batcher[A, B], sig os.Signal, rest ...os.Signal) {
+	onSignal(b, func() {
+		if err := b.Flush(); err != nil {
+			slog.Warn("microbatcher: flush on signal failed", "error", err)
+		}
+	}, append([]os.Signal{sig}, rest...))
+}
+
+// ShutdownOnSignal gracefully shuts down the Batcher when one of the given
+// signals is received, such as SIGTERM to drain before exiting. At least one
+// signal must be given. The signal handler is unregistered once the Batcher
+// shuts down.
+func ShutdownOnSignal[A any, B any](b *Batcher[A, B], sig os.Signal, rest ...os.Signal) {
+	onSignal(b, b.Shutdown, append([]os.Signal{sig}, rest...))
+}
+
+// onSignal calls action each time one of the given signals is received
+// until the Batcher shuts down. The signals must not be empty, as that
+// would relay every incoming signal.
+func onSignal[A any, B any](b *Batcher[A, B], action func(), sig []os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig...)
+
+	go func() {
+		defer signal.Stop(ch)
+
+		for {
+			select {
+			case <-b.done:
+				return
+			case <-ch:
+				action()
+			}
+		}
+	}()
+}
@@ -0,0 +1,121 @@
+//go:build unix
+
+package microbatcher
+
+import (
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFlushOnSignal(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+	FlushOnSignal(b, syscall.SIGUSR1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal("failed to send signal")
+	}
+
+	select {
+	case info := <-b.Flushed():
+		if info.Reason != FlushManual {
+			t.Error("queue was not flushed by the signal")
+		}
+	case <-time.After(time.Second):
+		t.Error("queue was not flushed by the signal")
+	}
+
+	if str, err := res.Get(); err != nil || str != "FOOBAR" {
+		t.Error("failed to process job 1 correctly")
+	}
+}
+
+func TestFlushOnSignalLogsError(t *testing.T) {
+	logs := captureLogs(t)
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithRequireStart[string, string]())
+	FlushOnSignal(b, syscall.SIGUSR1)
+	defer b.Shutdown()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal("failed to send signal")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(strings.Join(logs(), "\n"), ErrNotStarted.Error()) {
+		if time.Now().After(deadline) {
+			t.Error("flush error was not logged")
+			return
+		}
+
+		time.Sleep(ONE_MILLISECOND)
+	}
+}
+
+func TestShutdownOnSignal(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+	ShutdownOnSignal(b, syscall.SIGUSR2)
+
+	go b.Start()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatal("failed to send signal")
+	}
+
+	if str, err := res.Get(); err != nil || str != "FOOBAR" {
+		t.Error("shutdown did not flush remaining jobs")
+	}
+
+	// Wait for the shutdown to complete.
+	for range b.Flushed() {
+	}
+
+	if _, err := b.AddJob(Job[string]{Id: 2, Data: "foobar"}); err != ErrShuttingDown {
+		t.Error("batcher was not shut down by the signal")
+	}
+}
+
+func TestFlushOnSignalIgnoresOtherSignals(t *testing.T) {
+	// Capture SIGUSR2 so that it does not terminate the test process.
+	other := make(chan os.Signal, 1)
+	signal.Notify(other, syscall.SIGUSR2)
+	defer signal.Stop(other)
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+	FlushOnSignal(b, syscall.SIGUSR1)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	if _, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"}); err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatal("failed to send signal")
+	}
+
+	<-other
+
+	select {
+	case <-b.Flushed():
+		t.Error("queue was flushed by a signal that was not registered")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,135 @@
+package microbatcher
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the activity of a Batcher.
+type Stats struct {
+	// Number of jobs accepted by the Batcher, including duplicate jobs
+	// collapsed into a queued job.
+	Submitted uint64
+	// Number of jobs that have delivered a result.
+	Completed uint64
+	// Number of jobs currently waiting on the queue.
+	Queued int
+	// How long the oldest job on the queue has been waiting.
+	OldestPendingAge time.Duration
+	// Number of jobs flushed from the queue that are still processing.
+	InFlight int64
+	// Estimated size in bytes of the queued and in-flight jobs, when a
+	// memory budget is configured.
+	EstimatedBytes int64
+	// Number of jobs completed from the result cache.
+	CacheHits uint64
+	// Number of jobs that were not found in the result cache.
+	CacheMisses uint64
+	// Number of jobs the fault injector was run against, when chaos
+	// testing with WithFaultInjector.
+	FaultsInjected uint64
+
+	// Number of times the mutex was acquired, when lock metrics are
+	// enabled.
+	LockAcquisitions uint64
+	// Total time spent waiting to acquire the mutex, when lock metrics
+	// are enabled.
+	LockWait time.Duration
+	// Total time the mutex was held, when lock metrics are enabled.
+	LockHold time.Duration
+}
+
+// counters holds the running totals reported by Stats, which are updated
+// by processing goroutines without holding the mutex.
+type counters struct {
+	submitted atomic.Uint64
+	completed atomic.Uint64
+	inFlight  atomic.Int64
+
+	cacheHits   atomic.Uint64
+	cacheMisses atomic.Uint64
+
+	faultsInjected atomic.Uint64
+
+	lockAcquisitions atomic.Uint64
+	lockWait         atomic.Int64
+	lockHold         atomic.Int64
+}
+
+// Stats returns a snapshot of the activity of the Batcher. It is safe to
+// call at any point in the lifecycle of the Batcher, including after it
+// has shut down.
+func (b *Batcher[A, B]) Stats() Stats {
+	b.lock()
+	queued := len(b.jobs)
+	oldest := b.oldestPendingAge(time.Now())
+	jobSize := b.jobSize
+	b.unlock()
+
+	inFlight := b.stats.inFlight.Load()
+
+	return Stats{
+		Submitted:        b.stats.submitted.Load(),
+		Completed:        b.stats.completed.Load(),
+		Queued:           queued,
+		OldestPendingAge: oldest,
+		InFlight:         inFlight,
+		EstimatedBytes:   (int64(queued) + inFlight) * jobSize,
+
+		CacheHits:   b.stats.cacheHits.Load(),
+		CacheMisses: b.stats.cacheMisses.Load(),
+
+		FaultsInjected: b.stats.faultsInjected.Load(),
+
+		LockAcquisitions: b.stats.lockAcquisitions.Load(),
+		LockWait:         time.Duration(b.stats.lockWait.Load()),
+		LockHold:         time.Duration(b.stats.lockHold.Load()),
+	}
+}
+
+// OldestPendingAge returns how long the oldest job on the queue has been
+// waiting, or zero if the queue is empty. A steadily climbing age indicates
+// the queue is not being flushed or processing is stuck.
+func (b *Batcher[A, B]) OldestPendingAge() time.Duration {
+	b.lock()
+	defer b.unlock()
+
+	return b.oldestPendingAge(time.Now())
+}
+
+// oldestPendingAge returns the age of the oldest job on the queue, which
+// is always at its head as jobs are appended in order. The mutex must be
+// held.
+func (b *Batcher[A, B]) oldestPendingAge(now time.Time) time.Duration {
+	if len(b.jobs) == 0 {
+		return 0
+	}
+
+	return now.Sub(b.jobs[0].enqueued)
+}
+
+// lock acquires the mutex, measuring the time spent waiting for it if lock
+// metrics are enabled.
+func (b *Batcher[A, B]) lock() {
+	if !b.lockMetrics {
+		b.mu.Lock()
+		return
+	}
+
+	start := time.Now()
+	b.mu.Lock()
+	b.lockAcquired = time.Now()
+
+	b.stats.lockAcquisitions.Add(1)
+	b.stats.lockWait.Add(int64(b.lockAcquired.Sub(start)))
+}
+
+// unlock releases the mutex, measuring the time it was held for if lock
+// metrics are enabled.
+func (b *Batcher[A, B]) unlock() {
+	if b.lockMetrics {
+		b.stats.lockHold.Add(int64(time.Since(b.lockAcquired)))
+	}
+
+	b.mu.Unlock()
+}
@@ -0,0 +1,170 @@
+package microbatcher
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatcherStats(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 2)
+
+	for i := 1; i <= 3; i++ {
+		_, err := b.AddJob(Job[string]{Id: i, Data: "foobar"})
+		if err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	go b.Start()
+
+	// Wait for the first batch to be processed.
+	<-b.Flushed()
+
+	stats := b.Stats()
+	if stats.Submitted != 3 || stats.Completed != 2 || stats.Queued != 1 {
+		t.Error("incorrect stats before shutdown")
+	}
+
+	b.Shutdown()
+
+	stats = b.Stats()
+	if stats.Completed != 3 || stats.Queued != 0 || stats.InFlight != 0 {
+		t.Error("incorrect stats after shutdown")
+	}
+}
+
+func TestBatcherShutdownWaitsForInFlightJobs(t *testing.T) {
+	slow := func(in string) string {
+		time.Sleep(50 * time.Millisecond)
+		return strings.ToUpper(in)
+	}
+	b := NewBatcher(slow, FIVE_MINUTES, 1)
+
+	go b.Start()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	// Allow time for the job to be flushed before shutting down.
+	time.Sleep(10 * time.Millisecond)
+
+	b.Shutdown()
+
+	stats := b.Stats()
+	if stats.Completed != 1 || stats.InFlight != 0 {
+		t.Error("shutdown returned before in-flight job completed")
+	}
+
+	str, err := res.Get()
+	if err != nil || str != "FOOBAR" {
+		t.Error("in-flight job did not deliver its result after shutdown")
+	}
+
+	if _, ok := <-b.Flushed(); !ok {
+		t.Error("flush of in-flight job was not notified")
+	}
+
+	if _, ok := <-b.Flushed(); ok {
+		t.Error("flushed channel was not closed after shutdown")
+	}
+}
+
+func TestBatcherOldestPendingAge(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10)
+
+	if b.OldestPendingAge() != 0 {
+		t.Error("empty queue reported a pending age")
+	}
+
+	_, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = b.AddJob(Job[string]{Id: 2, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 2")
+	}
+
+	if b.OldestPendingAge() < 20*time.Millisecond {
+		t.Error("pending age does not reflect the oldest job")
+	}
+
+	if b.Stats().OldestPendingAge < 20*time.Millisecond {
+		t.Error("stats pending age does not reflect the oldest job")
+	}
+
+	b.Close()
+
+	if b.OldestPendingAge() != 0 {
+		t.Error("flushed queue reported a pending age")
+	}
+}
+
+func TestBatcherOldestPendingAgeAfterWeightedDequeue(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithWeightedPriority[string, string](map[int]int{1: 3, 0: 1}))
+
+	if _, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"}); err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	for i := 2; i <= 3; i++ {
+		if _, err := b.AddJob(Job[string]{Id: i, Data: "foobar", Priority: 1}); err != nil {
+			t.Errorf("failed to add job %d", i)
+		}
+	}
+
+	// Take the newer, higher priority job ahead of the oldest one.
+	b.lock()
+	batch := b.dequeue(1)
+	b.unlock()
+
+	if len(batch) != 1 || batch[0].job.Id != 2 {
+		t.Error("weighted dequeue did not take the high priority job")
+	}
+
+	if b.OldestPendingAge() < 20*time.Millisecond {
+		t.Error("pending age does not reflect the oldest job left on the queue")
+	}
+}
+
+func TestBatcherLockMetrics(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 1, WithLockMetrics[string, string]())
+
+	go b.Start()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if _, err := res.Get(); err != nil {
+		t.Error("failed to process job 1")
+	}
+
+	b.Shutdown()
+
+	stats := b.Stats()
+	if stats.LockAcquisitions == 0 || stats.LockHold == 0 {
+		t.Error("lock metrics were not recorded")
+	}
+}
+
+func TestBatcherLockMetricsDisabled(t *testing.T) {
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 1)
+
+	if _, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"}); err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if b.Stats().LockAcquisitions != 0 {
+		t.Error("lock metrics were recorded without being enabled")
+	}
+}
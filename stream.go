@@ -0,0 +1,90 @@
+package microbatcher
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// NewStreamingBatcher constructs a new Batcher like NewBulkBatcher, but with
+// a processor that emits the result for each job as soon as it is available,
+// such as from a streaming bulk API, rather than returning them all at once.
+//
+// The processor calls emit with the index of a job in the data it was given,
+// and that job's result is delivered immediately. Results may be emitted in
+// any order and from any goroutine, but only until the processor returns.
+// Every index should be emitted exactly once: repeated or out of range
+// indices are ignored, and jobs not emitted by the time the processor
+// returns fail with ErrResultNotEmitted.
+//
+// As results may already have been delivered, failed batches are not retried.
+func NewStreamingBatcher[A any, B any](processor func(ctx context.Context, data []A, emit func(i int, res B, err error)), frequency time.Duration, batchSize int, opts ...Option[A, B]) *Batcher[A, B] {
+	b := newBatcher(frequency, batchSize, opts...)
+	b.streamProcessor = processor
+
+	return b
+}
+
+// processStream processes the batch with a single call to the streaming
+// processor, completing each job as its result is emitted.
+func (b *Batcher[A, B]) processStream(batch []*batchJob[A, B]) {
+	batch = b.live(batch)
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := b.batchContext()
+	defer cancel()
+
+	ctx = b.withBatchKey(ctx, batch)
+
+	var mu sync.Mutex
+	emitted := make([]bool, len(batch))
+	start := time.Now()
+
+	emit := func(i int, res B, err error) {
+		mu.Lock()
+		if i < 0 || i >= len(batch) || emitted[i] {
+			mu.Unlock()
+			return
+		}
+		emitted[i] = true
+		mu.Unlock()
+
+		batch[i].duration = time.Since(start)
+		b.complete(batch[i], res, err)
+	}
+
+	data := make([]A, len(batch))
+	for i, job := range batch {
+		data[i] = job.job.Data
+	}
+
+	var zero B
+
+	if b.dryRun {
+		for i := range data {
+			emit(i, b.stub(data[i]), nil)
+		}
+	} else if err := b.injectFaults(data...); err != nil {
+		for i := range data {
+			emit(i, zero, err)
+		}
+	} else {
+		b.streamProcessor(ctx, data, emit)
+	}
+
+	// Fail any jobs that were not emitted, and ignore late emits.
+
+	for i := range batch {
+		mu.Lock()
+		missing := !emitted[i]
+		emitted[i] = true
+		mu.Unlock()
+
+		if missing {
+			batch[i].duration = time.Since(start)
+			b.complete(batch[i], zero, ErrResultNotEmitted)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package microbatcher
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestStreamingBatcherDeliversResultsAsEmitted(t *testing.T) {
+	release := make(chan struct{})
+	processor := func(ctx context.Context, in []string, emit func(int, string, error)) {
+		// Emit the last job first, and hold the rest back until it has
+		// been received.
+		emit(len(in)-1, strings.ToUpper(in[len(in)-1]), nil)
+
+		<-release
+
+		for i := range in[:len(in)-1] {
+			emit(i, strings.ToUpper(in[i]), nil)
+		}
+	}
+
+	b := NewStreamingBatcher(processor, FIVE_MINUTES, 3)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	results := addJobs(t, b, "hello world", "foobar", "baz")
+
+	if str, err := results[2].Get(); err != nil || str != "BAZ" {
+		t.Error("emitted result was not delivered before the batch completed")
+	}
+
+	close(release)
+
+	expected := []string{"HELLO WORLD", "FOOBAR"}
+	for i, res := range results[:2] {
+		if str, err := res.Get(); err != nil || str != expected[i] {
+			t.Errorf("failed to process job %d correctly", i)
+		}
+	}
+}
+
+func TestStreamingBatcherFailsJobsNotEmitted(t *testing.T) {
+	processor := func(ctx context.Context, in []string, emit func(int, string, error)) {
+		emit(0, "FOOBAR", nil)
+		// Repeated and out of range indices are ignored.
+		emit(0, "IGNORED", nil)
+		emit(len(in), "IGNORED", nil)
+	}
+
+	b := NewStreamingBatcher(processor, FIVE_MINUTES, 2)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	results := addJobs(t, b, "foobar", "baz")
+
+	if str, err := results[0].Get(); err != nil || str != "FOOBAR" {
+		t.Error("failed to process job 0 correctly")
+	}
+
+	if _, err := results[1].Get(); err != ErrResultNotEmitted {
+		t.Error("job 1 did not fail when its result was not emitted")
+	}
+}
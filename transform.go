@@ -0,0 +1,31 @@
+package microbatcher
+
+// transform replaces the data of each job in the batch with the output of
+// the batch transform, reporting false without changing the batch if the
+// transform did not return one element per job. The mutex must be held.
+func (b *Batcher[A, B]) transform(batch []*batchJob[A, B]) bool {
+	data := make([]A, len(batch))
+	for i, job := range batch {
+		data[i] = job.job.Data
+	}
+
+	data = b.batchTransform(data)
+	if len(data) != len(batch) {
+		return false
+	}
+
+	for i, job := range batch {
+		job.job.Data = data[i]
+	}
+
+	return true
+}
+
+// fail completes every job in the batch with the error, without processing
+// them.
+func (b *Batcher[A, B]) fail(batch []*batchJob[A, B], err error) {
+	var zero B
+	for _, job := range batch {
+		b.complete(job, zero, err)
+	}
+}
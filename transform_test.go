@@ -0,0 +1,73 @@
+package microbatcher
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBulkBatcherBatchTransform(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	processor := func(ctx context.Context, in []string) ([]string, error) {
+		mu.Lock()
+		received = slices.Clone(in)
+		mu.Unlock()
+
+		return uppercaseStrings(ctx, in)
+	}
+
+	transform := func(in []string) []string {
+		for i := range in {
+			in[i] = strings.TrimSpace(in[i])
+		}
+
+		return in
+	}
+
+	b := NewBulkBatcher(processor, FIVE_MINUTES, 2, WithBatchTransform[string, string](transform))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	results := addJobs(t, b, " foobar", "baz ")
+	expected := []string{"FOOBAR", "BAZ"}
+
+	for i, res := range results {
+		if str, err := res.Get(); err != nil || str != expected[i] {
+			t.Errorf("failed to process job %d correctly", i)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !slices.Equal(received, []string{"foobar", "baz"}) {
+		t.Error("processor did not receive the transformed batch")
+	}
+}
+
+func TestBatcherBatchTransformSizeMismatch(t *testing.T) {
+	processor := func(in string) string {
+		t.Error("processor called for a batch that could not be routed")
+		return in
+	}
+
+	transform := func(in []string) []string {
+		return in[:1]
+	}
+
+	b := NewBatcher(processor, FIVE_MINUTES, 2, WithBatchTransform[string, string](transform))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	for i, res := range addJobs(t, b, "foobar", "baz") {
+		if _, err := res.Get(); !errors.Is(err, ErrBatchSizeMismatch) {
+			t.Errorf("job %d did not fail with a size mismatch", i)
+		}
+	}
+}
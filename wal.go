@@ -0,0 +1,39 @@
+package microbatcher
+
+import "fmt"
+
+// WALWriter is a write-ahead log that records the jobs accepted by a
+// Batcher until they complete, so that jobs lost to a crash or restart can
+// be recovered. Storage is left to the implementation.
+//
+// On restart, every job that was appended but not marked done should be
+// replayed through AddJob. Jobs that were never processed, because they
+// were abandoned by a shutdown cut short by its context or timed out
+// waiting on the queue, are not marked done, so they are replayed too. As a job may complete just before a crash
+// without being marked done, replay gives at-least-once processing, so the
+// processor should be idempotent.
+type WALWriter[A any] interface {
+	// Append durably records a job before it is queued. It is called with
+	// the Batcher's mutex held, so that the log is in queue order, and
+	// must not call back into the Batcher. If Append returns an error, the
+	// job is rejected with that error.
+	Append(job Job[A]) error
+	// MarkDone records that the result of a job has been delivered, so it
+	// no longer needs to be replayed. A failure to record this only causes
+	// a redundant replay, so is left to the implementation to handle.
+	MarkDone(job Job[A])
+}
+
+// appendWAL appends the job to the write-ahead log, if one is configured.
+// The mutex must be held.
+func (b *Batcher[A, B]) appendWAL(job Job[A]) error {
+	if b.wal == nil {
+		return nil
+	}
+
+	if err := b.wal.Append(job); err != nil {
+		return fmt.Errorf("failed to add job; write-ahead log: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package microbatcher
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryWAL is a write-ahead log holding the pending jobs in memory.
+type memoryWAL struct {
+	mu      sync.Mutex
+	pending map[int]Job[string]
+	err     error
+}
+
+func newMemoryWAL() *memoryWAL {
+	return &memoryWAL{pending: map[int]Job[string]{}}
+}
+
+func (w *memoryWAL) Append(job Job[string]) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	w.pending[job.Id] = job
+
+	return nil
+}
+
+func (w *memoryWAL) MarkDone(job Job[string]) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.pending, job.Id)
+}
+
+func (w *memoryWAL) uncommitted() []Job[string] {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	jobs := []Job[string]{}
+	for _, job := range w.pending {
+		jobs = append(jobs, job)
+	}
+
+	return jobs
+}
+
+func TestBatcherWALMarksJobsDone(t *testing.T) {
+	wal := newMemoryWAL()
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithWAL[string, string](wal))
+
+	go b.Start()
+
+	addJobs(t, b, "hello world", "foobar")
+
+	if len(wal.uncommitted()) != 2 {
+		t.Error("accepted jobs were not appended to the log")
+	}
+
+	b.Shutdown()
+
+	if len(wal.uncommitted()) != 0 {
+		t.Error("completed jobs were not marked done")
+	}
+}
+
+func TestBatcherWALAppendFailureRejectsJob(t *testing.T) {
+	wal := newMemoryWAL()
+	wal.err = errors.New("disk full")
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithWAL[string, string](wal))
+
+	go b.Start()
+	defer b.Shutdown()
+
+	if _, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"}); !errors.Is(err, wal.err) {
+		t.Error("job was accepted without being appended to the log")
+	}
+
+	if b.Stats().Queued != 0 {
+		t.Error("rejected job was queued")
+	}
+}
+
+func TestBatcherWALReplayAfterRestart(t *testing.T) {
+	wal := newMemoryWAL()
+
+	// Queue jobs on a batcher that is abandoned without processing them,
+	// as if the process had crashed.
+	crashed := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithWAL[string, string](wal))
+	addJobs(t, crashed, "hello world", "foobar")
+	crashed.ticker.Stop()
+
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10, WithWAL[string, string](wal))
+
+	go b.Start()
+
+	for _, job := range wal.uncommitted() {
+		res, err := b.AddJob(job)
+		if err != nil {
+			t.Errorf("failed to replay job %d", job.Id)
+		}
+
+		b.Flush()
+
+		if _, err := res.Get(); err != nil {
+			t.Errorf("failed to process replayed job %d", job.Id)
+		}
+	}
+
+	b.Shutdown()
+
+	if len(wal.uncommitted()) != 0 {
+		t.Error("replayed jobs were not marked done")
+	}
+}
+
+func TestBatcherWALReplayAfterShutdownDeadline(t *testing.T) {
+	wal := newMemoryWAL()
+	release := make(chan struct{})
+	processor := func(in string) string {
+		<-release
+		return strings.ToUpper(in)
+	}
+
+	b := NewBatcher(processor, FIVE_MINUTES, 1, WithWAL[string, string](wal))
+	addJobs(t, b, "hello world", "foobar")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := b.ShutdownContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("shutdown did not return the context error")
+	}
+
+	close(release)
+
+	for range b.Flushed() {
+	}
+
+	uncommitted := wal.uncommitted()
+	if len(uncommitted) != 1 || uncommitted[0].Data != "foobar" {
+		t.Errorf("abandoned job was not left in the log: %v", uncommitted)
+		return
+	}
+
+	replayed := NewBatcher(uppercaseString, FIVE_MINUTES, 1, WithWAL[string, string](wal))
+
+	go replayed.Start()
+
+	res, err := replayed.AddJob(uncommitted[0])
+	if err != nil {
+		t.Error("failed to replay the abandoned job")
+	}
+
+	if out, err := res.Get(); err != nil || out != "FOOBAR" {
+		t.Error("failed to process the replayed job")
+	}
+
+	replayed.Shutdown()
+
+	if len(wal.uncommitted()) != 0 {
+		t.Error("replayed job was not marked done")
+	}
+}
+
+func TestBatcherWALKeepsExpiredJobs(t *testing.T) {
+	wal := newMemoryWAL()
+	b := NewBatcher(uppercaseString, FIVE_MINUTES, 10,
+		WithWAL[string, string](wal),
+		WithMaxQueueWait[string, string](10*time.Millisecond),
+	)
+
+	go b.Start()
+	defer b.Shutdown()
+
+	res, err := b.AddJob(Job[string]{Id: 1, Data: "foobar"})
+	if err != nil {
+		t.Error("failed to add job 1")
+	}
+
+	if _, err := res.Get(); err != ErrQueueTimeout {
+		t.Error("job 1 was not evicted from the queue")
+	}
+
+	if len(wal.uncommitted()) != 1 {
+		t.Error("expired job was marked done")
+	}
+}